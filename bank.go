@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"sort"
 	"time"
 
 	"golang.org/x/text/language"
@@ -27,12 +29,29 @@ var (
 
 	// ErrInvalidTransfer means the from account has insufficient funds or the transactions were invalid.
 	ErrInvalidTransfer = errors.New("Invalid transfer")
+
+	// ErrNoExchangeRate means there is no exchange rate between the currencies of a transfer
+	ErrNoExchangeRate = errors.New("No exchange rate")
+
+	// ErrNoInterbank means the bank is not a named household connected to the interbank service
+	ErrNoInterbank = errors.New("No interbank service")
+
+	// ErrUnknownHousehold means a household of the interbank service has no bank or a bank is not a household
+	ErrUnknownHousehold = errors.New("Unknown household")
+)
+
+// Transaction categories with special meaning to the summary report
+const (
+	CategoryTransfer    = "Transfer"
+	CategoryTaxes       = "Taxes"
+	CategoryLoanPayment = "Loan Payment"
 )
 
 // DefaultFormatter formats USD currency.
 var DefaultFormatter = message.NewPrinter(language.AmericanEnglish)
 
-// USD represents the US dollar
+// USD represents the US dollar. Accounts in other currencies hold their amounts in the minor units of
+// their currency.
 type USD int64
 
 func (u USD) String() string {
@@ -57,6 +76,7 @@ type Account interface {
 	Validate(tx Transaction) bool
 	Append(tx Transaction) error
 	Update(ctx context.Context, proc Process, bank *Bank, date time.Time)
+	Transactions() []Transaction
 	String() string
 }
 
@@ -66,16 +86,25 @@ func NewBankAccount(name string, date time.Time, init USD) *BankAccount {
 		Name:    name,
 		Balance: init,
 		Ledger: []Transaction{
-			Transaction{Date: date, Description: "Initial deposit", Type: Deposit, Amount: init},
+			Transaction{Date: date, Description: "Initial deposit", Type: Deposit, Amount: init, Balance: init},
 		},
 	}
 }
 
+// NewCurrencyAccount creates a new bank account in a currency other than US dollars. The initial
+// balance is in the minor units of the currency.
+func NewCurrencyAccount(name string, date time.Time, init USD, currency Currency) *BankAccount {
+	acct := NewBankAccount(name, date, init)
+	acct.Currency = currency
+	return acct
+}
+
 // BankAccount represents a bank account.
 type BankAccount struct {
-	Name    string
-	Balance USD
-	Ledger  []Transaction
+	Name     string
+	Balance  USD
+	Currency Currency
+	Ledger   []Transaction
 }
 
 // Update allows for the account to update account information periodically.
@@ -87,22 +116,27 @@ func (a *BankAccount) CurrentBalance() USD {
 	return a.Balance
 }
 
+// Transactions returns the account ledger
+func (a *BankAccount) Transactions() []Transaction {
+	return a.Ledger
+}
+
 // Append appends a transaction to the account
 func (a *BankAccount) Append(tx Transaction) error {
 	log.Println(a.Name, tx)
 	// log.Println(date.Format("2006/01/02"), item.Description())
 	if tx.Type == Deposit {
-		a.Ledger = append(a.Ledger, tx)
 		a.Balance += tx.Amount
 	} else if tx.Type == Withdrawal {
 		if tx.Amount > a.Balance {
 			return ErrInsufficientFunds
 		}
-		a.Ledger = append(a.Ledger, tx)
 		a.Balance -= tx.Amount
 	} else {
 		return ErrUnknownTransactionType
 	}
+	tx.Balance = a.Balance
+	a.Ledger = append(a.Ledger, tx)
 	return nil
 }
 
@@ -117,13 +151,26 @@ func (a *BankAccount) Validate(tx Transaction) bool {
 }
 
 func (a *BankAccount) String() string {
+	if a.Currency != "" {
+		return fmt.Sprintf("%s\t%s", a.Name, Money{int64(a.Balance), a.Currency})
+	}
 	return fmt.Sprintf("%s\t%s", a.Name, a.Balance)
 }
 
-// Bank represents a single user bank account
+// Bank represents a single user bank account. Named banks are households which can transfer money to
+// each other through the interbank service.
 type Bank struct {
+	Name      string
 	Accounts  map[string]Account
 	LineItems []LineItem
+	Returns   *ReturnTracker
+	Goals     []Goal
+	FX        FXRates
+
+	outbox []InterbankTransfer
+	seen   map[string]int
+	events []Event
+	goals  map[string]time.Time
 }
 
 // Append appends a transaction to the bank account ledger
@@ -151,14 +198,26 @@ func (b *Bank) Transfer(date time.Time, from, to string, ammt USD) error {
 		return ErrAccountDoesNotExist
 	}
 
-	// Check available funds
-	if fromAccount.CurrentBalance() < ammt {
+	// Check available funds. Accounts which sell investments to cover withdrawals are checked by Validate.
+	if _, ok := fromAccount.(Liquidator); !ok && fromAccount.CurrentBalance() < ammt {
 		return ErrInsufficientFunds
 	}
 
+	// Transfers between currencies are converted at the exchange rate
+	converted, err := b.Convert(date, ammt, accountCurrency(fromAccount), accountCurrency(toAccount))
+	if err != nil {
+		return err
+	}
+
+	// Payments to loans are spent rather than moved between the household's own accounts
+	category := CategoryTransfer
+	if _, ok := toAccount.(*LoanAccount); ok {
+		category = CategoryLoanPayment
+	}
+
 	desc := fmt.Sprintf("Transfer from '%s' to '%s'", from, to)
-	withdrawalTxn := Transaction{date, Withdrawal, desc, ammt}
-	despositTxn := Transaction{date, Deposit, desc, ammt}
+	withdrawalTxn := Transaction{Date: date, Type: Withdrawal, Description: desc, Category: category, Amount: ammt}
+	despositTxn := Transaction{Date: date, Type: Deposit, Description: desc, Category: category, Amount: converted}
 
 	if !fromAccount.Validate(withdrawalTxn) || !toAccount.Validate(despositTxn) {
 		return ErrInvalidTransfer
@@ -173,27 +232,146 @@ func (b *Bank) Transfer(date time.Time, from, to string, ammt USD) error {
 	return toAccount.Append(despositTxn)
 }
 
-// AddLineItem adds a line item to the bank.
+// Emit records an event which is sent to the bank outputs at the end of the day.
+func (b *Bank) Emit(date time.Time, account, kind, desc string) {
+	b.events = append(b.events, Event{Date: date, Account: account, Type: kind, Description: desc})
+}
+
+// names returns the sorted account names
+func (b *Bank) names() []string {
+	var names []string
+	for name := range b.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// broadcastAccounts sends the account names and types to the bank outputs.
+func (b *Bank) broadcastAccounts(proc Process) {
+	var accts []AccountDescription
+	for _, name := range b.names() {
+		kind := reflect.Indirect(reflect.ValueOf(b.Accounts[name])).Type().Name()
+		desc := AccountDescription{Name: name, Kind: kind}
+		if property, ok := b.Accounts[name].(*PropertyAccount); ok {
+			desc.Loan = property.Loan
+		}
+		accts = append(accts, desc)
+	}
+	proc.Children().Dispatch(Message{Timestamp: time.Now().UTC(), Type: TypeAccounts, Value: accts})
+}
+
+// balanceInfo returns the end of day balance of an account. The account value is the market value of
+// investment accounts and the negative principal outstanding of loans. For loans, the cash flow and interest
+// are the cumulative principal and interest paid.
+func balanceInfo(date time.Time, name string, acct Account) AccountInfo {
+	info := AccountInfo{
+		Date:          date,
+		Account:       name,
+		Period:        PeriodBalance,
+		AvailableCash: acct.CurrentBalance(),
+		AccountValue:  acct.CurrentBalance(),
+	}
+
+	switch a := acct.(type) {
+	case InvestmentAccount:
+		info.AccountValue = a.MarketValue()
+	case *LoanAccount:
+		info.AccountValue = -a.PayoffAmount()
+		info.CashFlow = a.PrincipalPaid
+		info.Interest = a.InterestPaid
+	}
+	return info
+}
+
+// broadcastBalances sends the end of day balance of every account to the bank outputs. Balances of accounts
+// in other currencies are converted to US dollars so they can be summed.
+func (b *Bank) broadcastBalances(proc Process, date time.Time) {
+	var balances []AccountInfo
+	for _, name := range b.names() {
+		acct := b.Accounts[name]
+		info := balanceInfo(date, name, acct)
+		info.AvailableCash = b.inUSD(date, acct, info.AvailableCash)
+		info.AccountValue = b.inUSD(date, acct, info.AccountValue)
+		balances = append(balances, info)
+	}
+	proc.Children().Dispatch(Message{Timestamp: time.Now().UTC(), Type: TypeDailyBalances, Value: balances})
+}
+
+// broadcastTransactions sends the transactions posted since the last broadcast and the day's events to the bank outputs.
+// Transactions of accounts in other currencies are converted to US dollars so they can be summed.
+func (b *Bank) broadcastTransactions(proc Process) {
+	if b.seen == nil {
+		b.seen = map[string]int{}
+	}
+
+	var txns []AccountTransaction
+	for _, name := range b.names() {
+		acct := b.Accounts[name]
+		ledger := acct.Transactions()
+		for _, tx := range ledger[b.seen[name]:] {
+			tx.Amount = b.inUSD(tx.Date, acct, tx.Amount)
+			tx.Balance = b.inUSD(tx.Date, acct, tx.Balance)
+			txns = append(txns, AccountTransaction{Account: name, Transaction: tx})
+		}
+		b.seen[name] = len(ledger)
+	}
+	if len(txns) > 0 {
+		proc.Children().Dispatch(Message{Timestamp: time.Now().UTC(), Type: TypeDailyTransactions, Value: txns})
+	}
+
+	if len(b.events) > 0 {
+		proc.Children().Dispatch(Message{Timestamp: time.Now().UTC(), Type: TypeEvents, Value: b.events})
+		b.events = nil
+	}
+}
+
+// AddLineItem adds a line item to the bank in priority order.
 func (b *Bank) AddLineItem(li LineItem) {
 	b.LineItems = append(b.LineItems, li)
+	b.sortLineItems()
 }
 
 // Handle handles incoming messages for the bank process.
 func (b *Bank) Handle(ctx context.Context, proc Process, msg Message) {
 	switch msg.Type {
+	case MessageTypeStart:
+		// Line items are processed in priority order
+		b.sortLineItems()
+		b.broadcastAccounts(proc)
 	case TypeDate:
 		date := msg.Value.(time.Time)
 
-		// Process line items
+		// Deposit transfers from other households
+		if b.interbank(ctx) {
+			b.settle(ctx, date)
+		}
+
 		for _, item := range b.LineItems {
 			if err := item.Process(date, b); err != nil {
 				log.Println("ERR: ", err)
 			}
 		}
 
-		// Update account information if necessary
-		for _, acct := range b.Accounts {
-			acct.Update(ctx, proc, b, date)
+		// Update account information if necessary. Accounts are updated in name order rather than map order so
+		// runs with the same seed are reproducible.
+		for _, name := range b.names() {
+			b.Accounts[name].Update(ctx, proc, b, date)
+		}
+		b.evaluateGoals(date)
+
+		// Record investment returns
+		if b.Returns != nil {
+			b.Returns.Record(date, b)
+		}
+
+		// Send transfers to other households before the day is complete
+		b.send(ctx, date)
+
+		b.broadcastTransactions(proc)
+		b.broadcastBalances(proc, date)
+		if b.interbank(ctx) {
+			b.completeDay(ctx)
 		}
 	}
 }
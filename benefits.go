@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Social Security parameters for the base year. The wage base and bend points grow with the average wage.
+const (
+	ssBaseYear    = 2024
+	ssWageBase    = 168600.
+	ssFirstBend   = 1174.
+	ssSecondBend  = 7078.
+	ssYearsCount  = 35
+	ssMaxClaimAge = 70
+)
+
+// SocialSecurity pays a monthly Social Security retirement benefit from the claim date. The benefit is
+// based on an earnings history of the prior earnings and the wages deposited into the account during the
+// simulation, each capped at the taxable wage base, and is adjusted for claiming before or after full
+// retirement age. Benefits increase each January by the cost of living adjustment.
+type SocialSecurity struct {
+	Account           string
+	Wages             string
+	BirthDate         time.Time
+	ClaimDate         time.Time
+	FullRetirementAge int
+	PriorEarnings     map[int]USD
+	WageGrowth        float64
+	COLA              float64
+	DayOfMonth        int
+
+	Earnings map[int]USD
+	Benefit  USD
+
+	seen    int
+	claimed bool
+}
+
+func (s *SocialSecurity) Description() string {
+	return fmt.Sprintf("SOCIAL SECURITY to %s from %s", s.Account, s.ClaimDate.Format("2006-01-02"))
+}
+
+// Priority returns the income priority.
+func (s *SocialSecurity) Priority() int {
+	return PriorityIncome
+}
+
+// wageBase returns the maximum taxable earnings for a year.
+func (s *SocialSecurity) wageBase(year int) USD {
+	return RoundHalfEven.Round(ssWageBase * 100 * math.Pow(1+s.WageGrowth/100., float64(year-ssBaseYear)))
+}
+
+// recordWages adds the wages deposited since the last call to the earnings history.
+func (s *SocialSecurity) recordWages(bank *Bank) {
+	acct, ok := bank.Accounts[s.Account]
+	if !ok {
+		return
+	}
+	if s.Earnings == nil {
+		s.Earnings = map[int]USD{}
+		for year, amount := range s.PriorEarnings {
+			s.Earnings[year] = amount
+		}
+	}
+
+	ledger := acct.Transactions()
+	for _, tx := range ledger[s.seen:] {
+		if tx.Type != Deposit || tx.Description != s.Wages {
+			continue
+		}
+		year := tx.Date.Year()
+		s.Earnings[year] += tx.Amount
+		if base := s.wageBase(year); s.Earnings[year] > base {
+			s.Earnings[year] = base
+		}
+	}
+	s.seen = len(ledger)
+}
+
+// PrimaryInsuranceAmount returns the monthly benefit at full retirement age before cost of living adjustments.
+// Earnings are indexed to the year the owner turns 60 and the highest 35 years are averaged.
+func (s *SocialSecurity) PrimaryInsuranceAmount() USD {
+	indexYear := s.BirthDate.Year() + 60
+	eligibleYear := s.BirthDate.Year() + 62
+
+	var indexed []float64
+	for year, amount := range s.Earnings {
+		v := amount.Float64()
+		if year < indexYear {
+			v *= math.Pow(1+s.WageGrowth/100., float64(indexYear-year))
+		}
+		indexed = append(indexed, v)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(indexed)))
+	if len(indexed) > ssYearsCount {
+		indexed = indexed[:ssYearsCount]
+	}
+
+	var total float64
+	for _, v := range indexed {
+		total += v
+	}
+	aime := total / (ssYearsCount * 12)
+
+	growth := math.Pow(1+s.WageGrowth/100., float64(eligibleYear-ssBaseYear))
+	first, second := ssFirstBend*growth, ssSecondBend*growth
+	pia := 0.9*math.Min(aime, first) + 0.32*math.Max(math.Min(aime, second)-first, 0) + 0.15*math.Max(aime-second, 0)
+	return RoundHalfEven.Round(pia * 100)
+}
+
+// claimAdjustment returns the factor applied to the benefit for claiming before or after full retirement
+// age. Early benefits are reduced 5/9 of a percent for each of the first 36 months and 5/12 of a percent for
+// each month after. Delayed benefits are increased 2/3 of a percent for each month up to age 70.
+func (s *SocialSecurity) claimAdjustment() float64 {
+	fra := s.BirthDate.AddDate(s.FullRetirementAge, 0, 0)
+	months := (s.ClaimDate.Year()-fra.Year())*12 + int(s.ClaimDate.Month()-fra.Month())
+	if max := (ssMaxClaimAge - s.FullRetirementAge) * 12; months > max {
+		months = max
+	}
+
+	if months >= 0 {
+		return 1 + float64(months)*2./3./100.
+	}
+	early := -months
+	if early <= 36 {
+		return 1 - float64(early)*5./9./100.
+	}
+	return 1 - 36*5./9./100. - float64(early-36)*5./12./100.
+}
+
+func (s *SocialSecurity) Process(date time.Time, bank *Bank) error {
+	s.recordWages(bank)
+	if date.Before(s.ClaimDate) {
+		return nil
+	}
+
+	if !s.claimed {
+		s.claimed = true
+		colas := math.Pow(1+s.COLA/100., float64(date.Year()-(s.BirthDate.Year()+62)))
+		s.Benefit = s.PrimaryInsuranceAmount().Mul(colas*s.claimAdjustment(), RoundHalfEven)
+		bank.Emit(date, s.Account, "SOCIAL SECURITY", fmt.Sprintf("Claimed a monthly benefit of %s", s.Benefit))
+	} else if date.Month() == time.January && date.Day() == 1 {
+		s.Benefit = s.Benefit.Mul(1+s.COLA/100., RoundHalfEven)
+	}
+
+	if date.Day() != s.DayOfMonth {
+		return nil
+	}
+	return bank.Append(s.Account, Transaction{Date: date, Type: Deposit, Description: "Social Security", Category: "Social Security", Amount: s.Benefit})
+}
+
+// Pension pays a monthly defined-benefit pension from the start date with an annual cost of living
+// adjustment each January. After the death date, if any, the survivor receives a percentage of the
+// benefit until the end date, if any.
+type Pension struct {
+	Account         string
+	Name            string
+	Amount          USD
+	COLA            float64
+	DayOfMonth      int
+	StartDate       time.Time
+	DeathDate       time.Time
+	SurvivorPercent float64
+	EndDate         time.Time
+
+	benefit USD
+}
+
+func (p *Pension) Description() string {
+	return fmt.Sprintf("%20s\t%s", p.Name, p.Amount)
+}
+
+// Priority returns the income priority.
+func (p *Pension) Priority() int {
+	return PriorityIncome
+}
+
+func (p *Pension) Process(date time.Time, bank *Bank) error {
+	if date.Before(p.StartDate) || (!p.EndDate.IsZero() && date.After(p.EndDate)) {
+		return nil
+	}
+
+	if p.benefit == 0 {
+		p.benefit = p.Amount
+		bank.Emit(date, p.Account, "PENSION", fmt.Sprintf("%s started paying %s", p.Name, p.Amount))
+	} else if date.Month() == time.January && date.Day() == 1 {
+		p.benefit = p.benefit.Mul(1+p.COLA/100., RoundHalfEven)
+	}
+
+	if date.Day() != p.DayOfMonth {
+		return nil
+	}
+
+	amount := p.benefit
+	if !p.DeathDate.IsZero() && !date.Before(p.DeathDate) {
+		amount = amount.Percent(p.SurvivorPercent, RoundHalfEven)
+	}
+	if amount <= 0 {
+		return nil
+	}
+	return bank.Append(p.Account, Transaction{Date: date, Type: Deposit, Description: p.Name, Category: "Pension", Amount: amount})
+}
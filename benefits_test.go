@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestClaimAdjustment(t *testing.T) {
+	born := time.Date(1960, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  int
+		want float64
+	}{
+		{62, 0.7},
+		{64, 1 - 36*5./9./100.},
+		{67, 1},
+		{70, 1.24},
+		{72, 1.24},
+	}
+	for _, tt := range tests {
+		s := &SocialSecurity{BirthDate: born, ClaimDate: born.AddDate(tt.age, 0, 0), FullRetirementAge: 67}
+		if got := s.claimAdjustment(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("claim at %d = %.4f, want %.4f", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestPrimaryInsuranceAmount(t *testing.T) {
+	// Born in 1962 the owner is eligible in the base year, so without wage growth the bend points are unchanged
+	born := time.Date(1962, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		years int
+		want  USD
+	}{
+		// An average of $5,000 a month is 90% of the first $1,174 and 32% of the rest
+		{35, 228092},
+		{40, 228092},
+
+		// Missing years count as zero
+		{10, 113806},
+	}
+	for _, tt := range tests {
+		s := &SocialSecurity{BirthDate: born, Earnings: map[int]USD{}}
+		for year := 1984; year < 1984+tt.years; year++ {
+			s.Earnings[year] = Dollars(60000)
+		}
+		if got := s.PrimaryInsuranceAmount(); got != tt.want {
+			t.Errorf("%d years: PIA = %s, want %s", tt.years, got, tt.want)
+		}
+	}
+}
+
+func TestSocialSecurityWages(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{"Checking": NewBankAccount("Checking", date, 0)}}
+	s := &SocialSecurity{
+		Account:       "Checking",
+		Wages:         "Salary",
+		BirthDate:     time.Date(1962, 1, 15, 0, 0, 0, 0, time.UTC),
+		ClaimDate:     time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC),
+		PriorEarnings: map[int]USD{2023: Dollars(50000)},
+		DayOfMonth:    3,
+	}
+
+	// Wages are capped at the taxable wage base
+	for _, month := range []time.Month{time.January, time.June} {
+		bank.Append("Checking", Transaction{Date: time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC), Type: Deposit, Description: "Salary", Amount: Dollars(100000)})
+	}
+	bank.Append("Checking", Transaction{Date: date, Type: Deposit, Description: "Bonus", Amount: Dollars(5000)})
+	if err := s.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if s.Earnings[2024] != Dollars(168600) || s.Earnings[2023] != Dollars(50000) {
+		t.Errorf("earnings = %v", s.Earnings)
+	}
+	if s.claimed || len(bank.events) != 0 {
+		t.Error("claimed before the claim date")
+	}
+}
+
+func TestPension(t *testing.T) {
+	start := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{"Checking": NewBankAccount("Checking", start, 0)}}
+	pension := &Pension{
+		Account:         "Checking",
+		Name:            "Pension",
+		Amount:          Dollars(1000),
+		COLA:            2,
+		DayOfMonth:      1,
+		StartDate:       start,
+		DeathDate:       time.Date(2046, 6, 1, 0, 0, 0, 0, time.UTC),
+		SurvivorPercent: 50,
+		EndDate:         time.Date(2046, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	// The benefit rises by the COLA each January and the survivor receives half after the death date
+	tests := []struct {
+		date time.Time
+		want USD
+	}{
+		{start, Dollars(1000)},
+		{time.Date(2046, 1, 1, 0, 0, 0, 0, time.UTC), Dollars(1020)},
+		{time.Date(2046, 6, 1, 0, 0, 0, 0, time.UTC), Dollars(510)},
+		{time.Date(2047, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+	}
+	for _, tt := range tests {
+		before := bank.Accounts["Checking"].CurrentBalance()
+		if err := pension.Process(tt.date, bank); err != nil {
+			t.Fatal(err)
+		}
+		if got := bank.Accounts["Checking"].CurrentBalance() - before; got != tt.want {
+			t.Errorf("%s: paid %s, want %s", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BudgetItem is the budgeted monthly amount for a category or a single line item. Expense budgets
+// match withdrawals and income budgets match deposits. The item is budgeted in the months between the
+// start and end dates, and a zero date leaves that end open.
+type BudgetItem struct {
+	Category  string
+	LineItem  string
+	Type      TransactionType
+	Amount    USD
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// Active returns true if the budget item is budgeted in the month.
+func (b BudgetItem) Active(month time.Time) bool {
+	if !b.EndDate.IsZero() && month.After(b.EndDate) {
+		return false
+	}
+	return b.StartDate.IsZero() || month.AddDate(0, 1, 0).After(b.StartDate)
+}
+
+// Name returns the category or line item name of the budget item.
+func (b BudgetItem) Name() string {
+	if b.Category != "" {
+		return b.Category
+	}
+	return b.LineItem
+}
+
+// Matches returns true if the transaction was posted for the budget item.
+func (b BudgetItem) Matches(tx Transaction) bool {
+	kind := b.Type
+	if kind == "" {
+		kind = Withdrawal
+	}
+	if tx.Type != kind {
+		return false
+	}
+	if b.Category != "" {
+		return tx.Category == b.Category
+	}
+	return tx.Description == b.LineItem
+}
+
+// Budget is a list of monthly budget items.
+type Budget []BudgetItem
+
+// BudgetVariance compares the budgeted and actual amounts of a budget item for a month and the year to date.
+type BudgetVariance struct {
+	Month     time.Time
+	Item      BudgetItem
+	Budget    USD
+	Actual    USD
+	YTDBudget USD
+	YTDActual USD
+}
+
+// Variance returns the monthly amount under budget. Negative variances are overspent.
+func (v BudgetVariance) Variance() USD {
+	if v.Item.Type == Deposit {
+		return v.Actual - v.Budget
+	}
+	return v.Budget - v.Actual
+}
+
+// YTDVariance returns the year to date amount under budget.
+func (v BudgetVariance) YTDVariance() USD {
+	if v.Item.Type == Deposit {
+		return v.YTDActual - v.YTDBudget
+	}
+	return v.YTDBudget - v.YTDActual
+}
+
+// Overspent returns true if the month is over budget.
+func (v BudgetVariance) Overspent() bool {
+	return v.Variance() < 0
+}
+
+// BudgetReport is the monthly budget variance of each budget item.
+type BudgetReport []BudgetVariance
+
+// Report compares the budget against the transactions posted to the given accounts, or every account if none
+// are given, for each month in the ledger the budget item is active.
+func (b Budget) Report(bank *Bank, accounts ...string) BudgetReport {
+	ledger := bank.Ledger(accounts...)
+	if len(ledger) == 0 {
+		return nil
+	}
+
+	// Monthly totals for each budget item
+	actuals := make([]map[time.Time]USD, len(b))
+	for i := range b {
+		actuals[i] = map[time.Time]USD{}
+	}
+	for _, entry := range ledger {
+		month := time.Date(entry.Date.Year(), entry.Date.Month(), 1, 0, 0, 0, 0, entry.Date.Location())
+		for i, item := range b {
+			if item.Matches(entry.Transaction) {
+				actuals[i][month] += entry.Amount
+			}
+		}
+	}
+
+	first, last := ledger[0].Date, ledger[len(ledger)-1].Date
+	start := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, first.Location())
+
+	var report BudgetReport
+	ytd := make([]BudgetVariance, len(b))
+	for month := start; !month.After(last); month = month.AddDate(0, 1, 0) {
+		for i, item := range b {
+			if month.Month() == time.January {
+				ytd[i] = BudgetVariance{}
+			}
+			if !item.Active(month) {
+				continue
+			}
+			ytd[i].YTDBudget += item.Amount
+			ytd[i].YTDActual += actuals[i][month]
+
+			report = append(report, BudgetVariance{
+				Month:     month,
+				Item:      item,
+				Budget:    item.Amount,
+				Actual:    actuals[i][month],
+				YTDBudget: ytd[i].YTDBudget,
+				YTDActual: ytd[i].YTDActual,
+			})
+		}
+	}
+	return report
+}
+
+// WriteCSV writes the monthly budget variances as CSV.
+func (r BudgetReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"month", "item", "type", "budget", "actual", "variance", "ytdbudget", "ytdactual", "ytdvariance", "overspent"})
+	for _, v := range r {
+		kind := v.Item.Type
+		if kind == "" {
+			kind = Withdrawal
+		}
+		out.Write([]string{
+			v.Month.Format("2006-01"),
+			v.Item.Name(),
+			string(kind),
+			formatDollars(v.Budget),
+			formatDollars(v.Actual),
+			formatDollars(v.Variance()),
+			formatDollars(v.YTDBudget),
+			formatDollars(v.YTDActual),
+			formatDollars(v.YTDVariance()),
+			fmt.Sprint(v.Overspent()),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// yearEnd returns true if the variance is the last month of its year the budget item is active.
+func (r BudgetReport) yearEnd(i int) bool {
+	for _, v := range r[i+1:] {
+		if v.Item == r[i].Item {
+			return v.Month.Year() != r[i].Month.Year()
+		}
+	}
+	return true
+}
+
+// String returns the year to date variance of each budget item at the end of every year. Expenses
+// over budget and income short of budget for the year are flagged.
+func (r BudgetReport) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Budget\n\t%s\t%-20s\t%s\t%s\t%s\n", "Year", "Item", "Budget", "Actual", "Variance")
+	for i, v := range r {
+		if !r.yearEnd(i) {
+			continue
+		}
+
+		flag := ""
+		if v.YTDVariance() < 0 && v.Item.Type == Deposit {
+			flag = "\tSHORT"
+		} else if v.YTDVariance() < 0 {
+			flag = "\tOVER"
+		}
+		fmt.Fprintf(&buf, "\t%d\t%-20s\t%s\t%s\t%s%s\n", v.Month.Year(), v.Item.Name(), v.YTDBudget, v.YTDActual, v.YTDVariance(), flag)
+	}
+	return buf.String()
+}
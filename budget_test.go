@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetReport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	start := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	checking := NewBankAccount("Checking", start, 0)
+	bank := &Bank{Accounts: map[string]Account{"Checking": checking}}
+
+	// Salary is paid through January and dining is overspent in December
+	for i, month := range []time.Time{start, start.AddDate(0, 1, 0), start.AddDate(0, 2, 0), start.AddDate(0, 3, 0)} {
+		if i < 3 {
+			checking.Append(Transaction{Date: month, Type: Deposit, Description: "Salary", Amount: Dollars(5000)})
+		}
+		dining := Dollars(300)
+		if month.Month() == time.December {
+			dining = Dollars(550)
+		}
+		checking.Append(Transaction{Date: month.AddDate(0, 0, 10), Type: Withdrawal, Category: "Dining", Amount: dining})
+	}
+
+	salary := BudgetItem{LineItem: "Salary", Type: Deposit, Amount: Dollars(6000), EndDate: time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)}
+	dining := BudgetItem{Category: "Dining", Amount: Dollars(400), StartDate: time.Date(2018, 12, 15, 0, 0, 0, 0, time.UTC)}
+	report := Budget{salary, dining}.Report(bank)
+
+	// Dining starts in December and salary ends in January. The year to date totals reset in January.
+	want := []struct {
+		month             string
+		item              string
+		variance          USD
+		ytdBudget, ytdAct USD
+	}{
+		{"2018-11", "Salary", -Dollars(1000), Dollars(6000), Dollars(5000)},
+		{"2018-12", "Salary", -Dollars(1000), Dollars(12000), Dollars(10000)},
+		{"2018-12", "Dining", -Dollars(150), Dollars(400), Dollars(550)},
+		{"2019-01", "Salary", -Dollars(1000), Dollars(6000), Dollars(5000)},
+		{"2019-01", "Dining", Dollars(100), Dollars(400), Dollars(300)},
+		{"2019-02", "Dining", Dollars(100), Dollars(800), Dollars(600)},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("%d variances, want %d: %v", len(report), len(want), report)
+	}
+	for i, w := range want {
+		v := report[i]
+		if v.Month.Format("2006-01") != w.month || v.Item.Name() != w.item {
+			t.Errorf("%d: %s %s, want %s %s", i, v.Month.Format("2006-01"), v.Item.Name(), w.month, w.item)
+			continue
+		}
+		if v.Variance() != w.variance || v.YTDBudget != w.ytdBudget || v.YTDActual != w.ytdAct {
+			t.Errorf("%s %s: variance %s ytd %s/%s, want %s ytd %s/%s", w.month, w.item, v.Variance(), v.YTDBudget, v.YTDActual, w.variance, w.ytdBudget, w.ytdAct)
+		}
+		if v.Overspent() != (w.variance < 0) {
+			t.Errorf("%s %s: overspent %v", w.month, w.item, v.Overspent())
+		}
+	}
+
+	// Income short of budget and expenses over budget are flagged at the end of each year the item is active
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")[2:]
+	wantLines := []string{
+		fmt.Sprintf("2018\t%-20s\t%s\t%s\t%s\tSHORT", "Salary", Dollars(12000), Dollars(10000), -Dollars(2000)),
+		fmt.Sprintf("2018\t%-20s\t%s\t%s\t%s\tOVER", "Dining", Dollars(400), Dollars(550), -Dollars(150)),
+		fmt.Sprintf("2019\t%-20s\t%s\t%s\t%s\tSHORT", "Salary", Dollars(6000), Dollars(5000), -Dollars(1000)),
+		fmt.Sprintf("2019\t%-20s\t%s\t%s\t%s", "Dining", Dollars(800), Dollars(600), Dollars(200)),
+	}
+	if len(lines) != len(wantLines) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(wantLines), report.String())
+	}
+	for i := range wantLines {
+		if strings.TrimSpace(lines[i]) != wantLines[i] {
+			t.Errorf("line %d = %q, want %q", i, strings.TrimSpace(lines[i]), wantLines[i])
+		}
+	}
+}
+
+func TestBudgetItemActive(t *testing.T) {
+	item := BudgetItem{StartDate: time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC)}
+	for month, want := range map[int]bool{2: false, 3: true, 6: true, 7: false} {
+		if got := item.Active(time.Date(2018, time.Month(month), 1, 0, 0, 0, 0, time.UTC)); got != want {
+			t.Errorf("month %d: active = %v, want %v", month, got, want)
+		}
+	}
+	if !(BudgetItem{}).Active(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("items without dates are always active")
+	}
+}
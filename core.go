@@ -194,6 +194,20 @@ func SendTo(ctx context.Context, svc string, msg Message) {
 	}
 }
 
+// HasService returns true if a service has been added to the context.
+func HasService(ctx context.Context, svc string) bool {
+	if v := ctx.Value(serviceKey); v != nil {
+		if svcs, ok := v.(ServiceList); ok {
+			for i := 0; i < len(svcs); i++ {
+				if svcs[i].Name() == svc {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // WithService adds a service to a context.Context.
 func WithService(ctx context.Context, svc Service) context.Context {
 	if v := ctx.Value(serviceKey); v != nil {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+)
+
+// DefaultHazard is the relative default likelihood over the life of a loan. Defaults
+// are rare in the first months, peak in the first half of the term and taper off.
+var DefaultHazard = []float64{0.3, 0.8, 1.2, 1.4, 1.4, 1.3, 1.2, 1.1, 1.0, 0.9, 0.8, 0.6}
+
+// DefaultCreditRisk is used for loans without a grade or with an unknown grade.
+var DefaultCreditRisk = &CreditRisk{
+	AnnualDefaultRate: 6,
+	Hazard:            DefaultHazard,
+	LatePaymentRate:   2,
+	LateFee:           15,
+	RecoveryRate:      10,
+	GracePeriod:       120,
+}
+
+// CreditRisk describes the credit behavior of a loan grade. Rates are percentages.
+type CreditRisk struct {
+
+	// AnnualDefaultRate is the percent of loans expected to default per year
+	AnnualDefaultRate float64
+
+	// Hazard is the relative default weight over the life of the loan. The curve is
+	// stretched over the loan term, so it applies to both 36 and 60 month loans.
+	Hazard []float64
+
+	// LatePaymentRate is the percent chance a payment is late
+	LatePaymentRate float64
+
+	// LateFee is charged to the borrower on late payments
+	LateFee USD
+
+	// RecoveryRate is the percent of the outstanding principal recovered by collections
+	RecoveryRate float64
+
+	// GracePeriod is the number of days a defaulted loan is late before it is charged off
+	GracePeriod int
+}
+
+// DefaultProbability returns the probability of the loan defaulting on the given
+// payment (zero-based) of a loan with the given term in months.
+func (c *CreditRisk) DefaultProbability(payment, term int) float64 {
+	monthly := 1 - math.Pow(1-c.AnnualDefaultRate/100., 1./12.)
+	if len(c.Hazard) == 0 || term <= 0 {
+		return monthly
+	}
+
+	var total float64
+	for _, h := range c.Hazard {
+		total += h
+	}
+	if total <= 0 {
+		return monthly
+	}
+
+	i := payment * len(c.Hazard) / term
+	if i >= len(c.Hazard) {
+		i = len(c.Hazard) - 1
+	}
+	return monthly * c.Hazard[i] * float64(len(c.Hazard)) / total
+}
+
+// Recovery returns the amount collected on the outstanding principal of a charged-off loan.
+func (c *CreditRisk) Recovery(outstanding USD) USD {
+	return outstanding.Percent(c.RecoveryRate, RoundHalfEven)
+}
+
+// CreditModel maps loan grades to their credit risk.
+type CreditModel map[string]*CreditRisk
+
+// Risk returns the credit risk for a loan grade.
+func (m CreditModel) Risk(grade string) *CreditRisk {
+	if risk, ok := m[grade]; ok && risk != nil {
+		return risk
+	}
+	return DefaultCreditRisk
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDefaultProbability(t *testing.T) {
+	risk := &CreditRisk{AnnualDefaultRate: 6, Hazard: DefaultHazard}
+	monthly := 1 - math.Pow(0.94, 1./12.)
+
+	// The hazard curve reshapes the defaults without changing the average over the term
+	for _, term := range []int{12, 36, 60} {
+		var total float64
+		for payment := 0; payment < term; payment++ {
+			total += risk.DefaultProbability(payment, term)
+		}
+		if avg := total / float64(term); math.Abs(avg-monthly) > 1e-12 {
+			t.Errorf("term %d: average = %g, want %g", term, avg, monthly)
+		}
+	}
+
+	if early, peak := risk.DefaultProbability(0, 36), risk.DefaultProbability(12, 36); early >= peak {
+		t.Errorf("first payment %g should be less likely to default than the peak %g", early, peak)
+	}
+	if got := risk.DefaultProbability(99, 36); got != risk.DefaultProbability(35, 36) {
+		t.Errorf("payments past the term should use the last hazard, got %g", got)
+	}
+
+	flat := &CreditRisk{AnnualDefaultRate: 6}
+	if got := flat.DefaultProbability(5, 36); math.Abs(got-monthly) > 1e-12 {
+		t.Errorf("flat = %g, want %g", got, monthly)
+	}
+}
+
+func TestCreditModel(t *testing.T) {
+	model := DefaultLoanGrades.CreditModel()
+	if got := model.Risk("A").AnnualDefaultRate; got != 2 {
+		t.Errorf("grade A default rate = %g", got)
+	}
+	if got := model.Risk("Z"); got != DefaultCreditRisk {
+		t.Errorf("unknown grade should use the default risk")
+	}
+
+	risk := &CreditRisk{RecoveryRate: 10}
+	if got := risk.Recovery(USD(2505)); got != USD(250) {
+		t.Errorf("recovery = %d, want 250", got)
+	}
+}
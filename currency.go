@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// Supported currencies
+const (
+	CurrencyUSD = Currency("USD")
+	CurrencyEUR = Currency("EUR")
+	CurrencyGBP = Currency("GBP")
+	CurrencyJPY = Currency("JPY")
+)
+
+// currencyFormat describes how amounts of a currency are stored and formatted
+type currencyFormat struct {
+	Symbol      string
+	MinorUnits  int
+	Decimal     string
+	SymbolAfter bool
+	Printer     *message.Printer
+}
+
+// currencyFormats are the formats of the supported currencies
+var currencyFormats = map[Currency]currencyFormat{
+	CurrencyUSD: {"$", 2, ".", false, DefaultFormatter},
+	CurrencyEUR: {"€", 2, ",", true, message.NewPrinter(language.German)},
+	CurrencyGBP: {"£", 2, ".", false, message.NewPrinter(language.BritishEnglish)},
+	CurrencyJPY: {"¥", 0, "", false, message.NewPrinter(language.Japanese)},
+}
+
+// format returns the format of the currency. Unknown currencies are formatted with their code.
+func (c Currency) format() currencyFormat {
+	if f, ok := currencyFormats[c]; ok {
+		return f
+	}
+	return currencyFormat{string(c) + " ", 2, ".", false, DefaultFormatter}
+}
+
+// scale returns the number of minor units in a major unit of the currency.
+func (c Currency) scale() int64 {
+	return int64(math.Pow10(c.format().MinorUnits))
+}
+
+// Money is an amount in the minor units of a currency, such as cents or pence.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// String formats the amount for the locale of the currency. Negative amounts are in parentheses.
+func (m Money) String() string {
+	f := m.Currency.format()
+	amount := m.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+
+	scale := m.Currency.scale()
+	s := f.Printer.Sprintf("%d", amount/scale)
+	if f.MinorUnits > 0 {
+		s += fmt.Sprintf("%s%0*d", f.Decimal, f.MinorUnits, amount%scale)
+	}
+	if f.SymbolAfter {
+		s += " " + f.Symbol
+	} else {
+		s = f.Symbol + s
+	}
+
+	if m.Amount < 0 {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// Convert converts the amount to another currency at the given rate, rounding half up to the nearest minor unit.
+func (m Money) Convert(to Currency, rate float64) Money {
+	factor := rate * float64(to.scale()) / float64(m.Currency.scale())
+	return Money{Amount: int64(USD(m.Amount).Mul(factor, RoundHalfUp)), Currency: to}
+}
+
+// FXRates provides the exchange rates between currencies.
+type FXRates interface {
+	Rate(date time.Time, from, to Currency) (float64, error)
+}
+
+// FixedRates are constant exchange rates given as the units of each currency per US dollar.
+type FixedRates map[Currency]float64
+
+// Rate returns the units of the target currency per unit of the source currency.
+func (f FixedRates) Rate(date time.Time, from, to Currency) (float64, error) {
+	perDollar := func(c Currency) (float64, bool) {
+		if c == CurrencyUSD {
+			return 1, true
+		}
+		rate, ok := f[c]
+		return rate, ok && rate > 0
+	}
+
+	fromRate, ok := perDollar(from)
+	if !ok {
+		return 0, ErrNoExchangeRate
+	}
+	toRate, ok := perDollar(to)
+	if !ok {
+		return 0, ErrNoExchangeRate
+	}
+	return toRate / fromRate, nil
+}
+
+// accountCurrency returns the currency of an account. Accounts are in US dollars unless they are
+// bank accounts in another currency.
+func accountCurrency(acct Account) Currency {
+	if a, ok := acct.(*BankAccount); ok && a.Currency != "" {
+		return a.Currency
+	}
+	return CurrencyUSD
+}
+
+// Convert converts an amount in the minor units of one currency to another using the exchange rates of the bank.
+func (b *Bank) Convert(date time.Time, amount USD, from, to Currency) (USD, error) {
+	if from == to {
+		return amount, nil
+	}
+	if b.FX == nil {
+		return 0, ErrNoExchangeRate
+	}
+
+	rate, err := b.FX.Rate(date, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return USD(Money{Amount: int64(amount), Currency: from}.Convert(to, rate).Amount), nil
+}
+
+// inUSD converts an amount of an account to US dollars so that it can be summed with the amounts of other
+// accounts. Amounts which cannot be converted are logged and returned as is.
+func (b *Bank) inUSD(date time.Time, acct Account, amount USD) USD {
+	currency := accountCurrency(acct)
+	if currency == CurrencyUSD {
+		return amount
+	}
+
+	converted, err := b.Convert(date, amount, currency, CurrencyUSD)
+	if err != nil {
+		log.Println("ERR: ", err)
+		return amount
+	}
+	return converted
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{12345, CurrencyUSD}, "$123.45"},
+		{Money{1234, CurrencyEUR}, "12,34 €"},
+		{Money{-1234, CurrencyEUR}, "(12,34 €)"},
+		{Money{5, CurrencyEUR}, "0,05 €"},
+		{Money{500, CurrencyJPY}, "¥500"},
+		{Money{-500, CurrencyJPY}, "(¥500)"},
+		{Money{999, CurrencyGBP}, "£9.99"},
+		{Money{150, Currency("CHF")}, "CHF 1.50"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%d %s = %q, want %q", tt.money.Amount, tt.money.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestFixedRates(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	rates := FixedRates{CurrencyEUR: 0.92, CurrencyGBP: 0.79, CurrencyJPY: 150}
+
+	tests := []struct {
+		from, to Currency
+		want     float64
+	}{
+		{CurrencyUSD, CurrencyUSD, 1},
+		{CurrencyUSD, CurrencyEUR, 0.92},
+		{CurrencyEUR, CurrencyUSD, 1 / 0.92},
+		{CurrencyEUR, CurrencyGBP, 0.79 / 0.92},
+		{CurrencyGBP, CurrencyJPY, 150 / 0.79},
+	}
+	for _, tt := range tests {
+		got, err := rates.Rate(date, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("%s/%s: %v", tt.from, tt.to, err)
+		} else if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s/%s = %f, want %f", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if _, err := rates.Rate(date, CurrencyEUR, Currency("CHF")); err != ErrNoExchangeRate {
+		t.Errorf("err = %v, want %v", err, ErrNoExchangeRate)
+	}
+	if _, err := (FixedRates{CurrencyEUR: 0}).Rate(date, CurrencyEUR, CurrencyUSD); err != ErrNoExchangeRate {
+		t.Errorf("zero rate err = %v, want %v", err, ErrNoExchangeRate)
+	}
+}
+
+func TestMoneyConvert(t *testing.T) {
+	// Yen have no minor units, so $1.00 is ¥150 and ¥1,000 is $6.67 rounded half up
+	if got := (Money{100, CurrencyUSD}).Convert(CurrencyJPY, 150); got != (Money{150, CurrencyJPY}) {
+		t.Errorf("USD to JPY = %v", got)
+	}
+	if got := (Money{1000, CurrencyJPY}).Convert(CurrencyUSD, 1./150); got != (Money{667, CurrencyUSD}) {
+		t.Errorf("JPY to USD = %v", got)
+	}
+}
+
+func TestLedgerInUSD(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{
+		Accounts: map[string]Account{
+			"Checking":     NewBankAccount("Checking", date, Dollars(1000)),
+			"Euro Savings": NewCurrencyAccount("Euro Savings", date, 0, CurrencyEUR),
+		},
+		FX: FixedRates{CurrencyEUR: 0.8},
+	}
+	if err := bank.Transfer(date, "Checking", "Euro Savings", Dollars(500)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The €400 deposit is reported as $500 alongside the dollar withdrawal
+	if got := bank.Accounts["Euro Savings"].CurrentBalance(); got != Dollars(400) {
+		t.Fatalf("euro balance = %s", got)
+	}
+	for _, entry := range bank.Ledger() {
+		if entry.Description != "Initial deposit" && (entry.Amount != Dollars(500) || (entry.Account == "Euro Savings" && entry.Balance != Dollars(500))) {
+			t.Errorf("%s %s = %s, balance %s", entry.Account, entry.Type, entry.Amount, entry.Balance)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// WithdrawalStrategy decides how much to withdraw from a retirement portfolio each year.
+type WithdrawalStrategy interface {
+	Withdrawal(date time.Time, portfolio USD) USD
+}
+
+// Refiller is implemented by withdrawal strategies which move money between the portfolio accounts
+// before each year of withdrawals.
+type Refiller interface {
+	Refill(date time.Time, bank *Bank, d *Decumulation, annual USD)
+}
+
+// FixedWithdrawal withdraws a percentage of the portfolio in the first year and increases the amount by
+// inflation every year after, such as the 4% rule.
+type FixedWithdrawal struct {
+	Rate      float64
+	Inflation float64
+
+	amount USD
+}
+
+// Withdrawal returns the inflation adjusted withdrawal for the year.
+func (f *FixedWithdrawal) Withdrawal(date time.Time, portfolio USD) USD {
+	if f.amount == 0 {
+		f.amount = portfolio.Percent(f.Rate, RoundHalfEven)
+	} else {
+		f.amount = f.amount.Mul(1+f.Inflation/100., RoundHalfEven)
+	}
+	return f.amount
+}
+
+// Guardrails withdraws a percentage of the portfolio in the first year and increases the amount by inflation
+// every year after. When the withdrawal rate rises above the upper guardrail the withdrawal is cut, and when
+// it falls below the lower guardrail the withdrawal is raised. The guardrails are percentages of the initial rate.
+type Guardrails struct {
+	Rate       float64
+	Inflation  float64
+	Upper      float64
+	Lower      float64
+	Adjustment float64
+
+	amount USD
+}
+
+// Withdrawal returns the inflation adjusted withdrawal for the year, adjusted if it crosses a guardrail.
+func (g *Guardrails) Withdrawal(date time.Time, portfolio USD) USD {
+	if g.amount == 0 {
+		g.amount = portfolio.Percent(g.Rate, RoundHalfEven)
+		return g.amount
+	}
+
+	amount := float64(g.amount) * (1 + g.Inflation/100.)
+	if portfolio > 0 {
+		rate := amount / float64(portfolio) * 100
+		if rate > g.Rate*(1+g.Upper/100.) {
+			amount *= 1 - g.Adjustment/100.
+		} else if rate < g.Rate*(1-g.Lower/100.) {
+			amount *= 1 + g.Adjustment/100.
+		}
+	}
+	g.amount = RoundHalfEven.Round(amount)
+	return g.amount
+}
+
+// BucketStrategy withdraws a fixed amount of spending which increases by inflation every year. The spending
+// is withdrawn from the cash bucket, which is refilled each year to cover the given number of years of spending.
+// The bucket is not refilled after a year in which the portfolio lost value.
+type BucketStrategy struct {
+	Spending  USD
+	Inflation float64
+	Cash      string
+	Years     float64
+
+	amount    USD
+	portfolio USD
+}
+
+// Withdrawal returns the inflation adjusted spending for the year.
+func (b *BucketStrategy) Withdrawal(date time.Time, portfolio USD) USD {
+	if b.amount == 0 {
+		b.amount = b.Spending
+	} else {
+		b.amount = b.amount.Mul(1+b.Inflation/100., RoundHalfEven)
+	}
+	return b.amount
+}
+
+// Refill refills the cash bucket from the other portfolio accounts unless the portfolio lost value.
+func (b *BucketStrategy) Refill(date time.Time, bank *Bank, d *Decumulation, annual USD) {
+	portfolio := d.PortfolioValue(date, bank)
+	defer func() { b.portfolio = portfolio }()
+	if portfolio < b.portfolio {
+		return
+	}
+
+	cash, ok := accountBalance(bank, b.Cash)
+	if !ok {
+		return
+	}
+	if target := annual.Mul(b.Years, RoundHalfEven); cash < target {
+		d.withdraw(date, bank, b.Cash, target-cash, b.Cash)
+	}
+}
+
+// taxOrder returns the order accounts are withdrawn from: taxable accounts, then tax-deferred and then Roth.
+func taxOrder(acct Account) int {
+	if r, ok := acct.(*RetirementAccount); ok {
+		if r.Treatment == Roth {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// withdrawable returns the most which can be withdrawn from an account. Retirement withdrawals must also cover
+// the penalty and withholding, accounts which sell investments to cover withdrawals can withdraw their
+// liquidation value, and other accounts must keep a balance.
+func withdrawable(acct Account, date time.Time) USD {
+	switch a := acct.(type) {
+	case *RetirementAccount:
+		penalty, withholding := a.charges(Transaction{Date: date, Amount: Dollars(100)})
+		return a.Balance.Div(1+float64(penalty+withholding)/float64(Dollars(100)), RoundTruncate)
+	case Liquidator:
+		return a.LiquidationValue() - 1
+	}
+	return acct.CurrentBalance() - 1
+}
+
+// Decumulation withdraws from the portfolio accounts to the spending account each month after the retirement
+// date. The yearly withdrawal is decided by the strategy on the retirement date and every anniversary. Taxable
+// accounts are withdrawn from first, then tax-deferred and then Roth accounts.
+type Decumulation struct {
+	To         string
+	Sources    []string
+	Strategy   WithdrawalStrategy
+	StartDate  time.Time
+	DayOfMonth int
+
+	Withdrawn USD
+	Depleted  time.Time
+	Terminal  USD
+
+	annual USD
+	review time.Time
+}
+
+func (d *Decumulation) Description() string {
+	return fmt.Sprintf("DECUMULATION from %v to %s after %s", d.Sources, d.To, d.StartDate.Format("2006-01-02"))
+}
+
+// Priority returns the income priority since withdrawals pay for the month's expenses.
+func (d *Decumulation) Priority() int {
+	return PriorityIncome
+}
+
+// sources returns the portfolio accounts in withdrawal order. The cash bucket of a bucket strategy is
+// withdrawn from first.
+func (d *Decumulation) sources(bank *Bank) []string {
+	var cash string
+	if b, ok := d.Strategy.(*BucketStrategy); ok {
+		cash = b.Cash
+	}
+
+	sources := append([]string(nil), d.Sources...)
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i] == cash || sources[j] == cash {
+			return sources[i] == cash && sources[j] != cash
+		}
+		return taxOrder(bank.Accounts[sources[i]]) < taxOrder(bank.Accounts[sources[j]])
+	})
+	return sources
+}
+
+// PortfolioValue returns the value of the portfolio accounts in US dollars at the exchange rates of the date.
+func (d *Decumulation) PortfolioValue(date time.Time, bank *Bank) USD {
+	var total USD
+	for _, name := range d.Sources {
+		if acct, ok := bank.Accounts[name]; ok {
+			total += bank.inUSD(date, acct, balanceInfo(date, name, acct).AccountValue)
+		}
+	}
+	return total
+}
+
+// withdraw transfers up to the amount from the portfolio accounts, other than the excluded account, in
+// withdrawal order and returns the amount transferred.
+func (d *Decumulation) withdraw(date time.Time, bank *Bank, to string, amount USD, exclude string) USD {
+	var total USD
+	for _, name := range d.sources(bank) {
+		acct, ok := bank.Accounts[name]
+		if !ok || name == exclude || total >= amount {
+			continue
+		}
+
+		available := withdrawable(acct, date)
+		if need := amount - total; available > need {
+			available = need
+		}
+		if available <= 0 {
+			continue
+		}
+		if err := bank.Transfer(date, name, to, available); err == nil {
+			total += available
+		}
+	}
+	return total
+}
+
+func (d *Decumulation) Process(date time.Time, bank *Bank) error {
+	if date.Before(d.StartDate) {
+		return nil
+	}
+	portfolio := d.PortfolioValue(date, bank)
+
+	if d.review.IsZero() {
+		d.review = d.StartDate
+		bank.Emit(date, d.To, "RETIRED", fmt.Sprintf("Retired with a portfolio of %s", portfolio))
+	}
+	if !date.Before(d.review) {
+		d.annual = d.Strategy.Withdrawal(date, portfolio)
+		if r, ok := d.Strategy.(Refiller); ok {
+			r.Refill(date, bank, d, d.annual)
+		}
+		d.review = d.review.AddDate(1, 0, 0)
+		bank.Emit(date, d.To, "WITHDRAWAL", fmt.Sprintf("Withdrawing %s this year", d.annual))
+	}
+
+	// Terminal wealth is what is left after the day's withdrawal
+	defer func() { d.Terminal = d.PortfolioValue(date, bank) }()
+	if date.Day() != d.DayOfMonth || !d.Depleted.IsZero() {
+		return nil
+	}
+
+	// The yearly withdrawal is split so that the months add up to it exactly
+	monthly := d.annual.Split(12)[(int(date.Month())-int(d.StartDate.Month())+12)%12]
+	withdrawn := d.withdraw(date, bank, d.To, monthly, d.To)
+	d.Withdrawn += withdrawn
+	if withdrawn < monthly && d.PortfolioValue(date, bank) < monthly {
+		d.Depleted = date
+		bank.Emit(date, d.To, "DEPLETED", "Portfolio depleted")
+	}
+	return nil
+}
+
+// String returns the depletion date or terminal wealth of the portfolio.
+func (d *Decumulation) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Decumulation\n")
+	fmt.Fprintf(&buf, "\t- %s\t%s\n", "Retirement:\t", d.StartDate.Format("2006-01-02"))
+	fmt.Fprintf(&buf, "\t- %s\t%s\n", "Withdrawn:\t", d.Withdrawn)
+	if d.Depleted.IsZero() {
+		fmt.Fprintf(&buf, "\t- %s\t%s\n", "Terminal Wealth:", d.Terminal)
+	} else {
+		fmt.Fprintf(&buf, "\t- %s\t%s\n", "Depleted:\t", d.Depleted.Format("2006-01-02"))
+	}
+	return buf.String()
+}
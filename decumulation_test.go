@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithdrawalStrategies(t *testing.T) {
+	date := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fixed := &FixedWithdrawal{Rate: 4, Inflation: 3}
+	if got := fixed.Withdrawal(date, Dollars(1000000)); got != Dollars(40000) {
+		t.Errorf("fixed first year = %s", got)
+	}
+	if got := fixed.Withdrawal(date.AddDate(1, 0, 0), Dollars(500000)); got != Dollars(41200) {
+		t.Errorf("fixed second year = %s", got)
+	}
+
+	// A 41,200 withdrawal from 800,000 is a 5.15% rate, above the 4.8% upper guardrail, so it is cut by 10%
+	guardrails := &Guardrails{Rate: 4, Inflation: 3, Upper: 20, Lower: 20, Adjustment: 10}
+	guardrails.Withdrawal(date, Dollars(1000000))
+	if got := guardrails.Withdrawal(date.AddDate(1, 0, 0), Dollars(800000)); got != Dollars(37080) {
+		t.Errorf("guardrails cut = %s", got)
+	}
+
+	// A 38,192.40 withdrawal from 1,500,000 is below the 3.2% lower guardrail, so it is raised by 10%
+	if got := guardrails.Withdrawal(date.AddDate(2, 0, 0), Dollars(1500000)); got != 4201164 {
+		t.Errorf("guardrails raise = %s", got)
+	}
+}
+
+func TestDecumulationLiquidates(t *testing.T) {
+	date := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+	investment := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	investment.Market = &SecondaryMarket{TradingFee: 1, LiquidationMarkup: -2}
+	investNotes(investment, date, 4)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking":   NewBankAccount("Checking", date, 0),
+		"Investment": investment,
+	}}
+
+	// The first month of 48% of the $100 portfolio needs a note to be sold
+	d := &Decumulation{To: "Checking", Sources: []string{"Investment"}, Strategy: &FixedWithdrawal{Rate: 48}, StartDate: date, DayOfMonth: 1}
+	if err := d.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if d.Withdrawn != Dollars(4) || bank.Accounts["Checking"].CurrentBalance() != Dollars(4) {
+		t.Errorf("withdrawn = %s", d.Withdrawn)
+	}
+	if investment.AvailableCash != 2426-400 || !d.Depleted.IsZero() {
+		t.Errorf("cash = %s, depleted = %s", investment.AvailableCash, d.Depleted)
+	}
+}
+
+func TestBucketStrategyCashFirst(t *testing.T) {
+	date := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking":  NewBankAccount("Checking", date, 0),
+		"Brokerage": NewBankAccount("Brokerage", date, Dollars(100000)),
+		"Cash":      NewBankAccount("Cash", date, Dollars(20000)),
+	}}
+	strategy := &BucketStrategy{Spending: Dollars(12000), Cash: "Cash", Years: 1}
+	d := &Decumulation{To: "Checking", Sources: []string{"Brokerage", "Cash"}, Strategy: strategy, StartDate: date, DayOfMonth: 1}
+
+	// The cash bucket already covers a year of spending, so it is not refilled and pays the month
+	if err := d.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if got := bank.Accounts["Cash"].CurrentBalance(); got != Dollars(19000) {
+		t.Errorf("cash = %s", got)
+	}
+	if got := bank.Accounts["Brokerage"].CurrentBalance(); got != Dollars(100000) {
+		t.Errorf("brokerage = %s", got)
+	}
+
+	// A year later the portfolio has grown, so the bucket is refilled from the brokerage account before the
+	// month is paid
+	bank.Accounts["Cash"].Append(Transaction{Date: date, Type: Withdrawal, Amount: Dollars(18000)})
+	bank.Accounts["Brokerage"].Append(Transaction{Date: date, Type: Deposit, Amount: Dollars(30000)})
+	next := date.AddDate(1, 0, 0)
+	if err := d.Process(next, bank); err != nil {
+		t.Fatal(err)
+	}
+	if got := bank.Accounts["Cash"].CurrentBalance(); got != Dollars(12000-1000) {
+		t.Errorf("refilled cash = %s", got)
+	}
+	if got := bank.Accounts["Brokerage"].CurrentBalance(); got != Dollars(130000-11000) {
+		t.Errorf("brokerage after refill = %s", got)
+	}
+}
+
+// yearlyRates are exchange rates which change each year.
+type yearlyRates map[int]FixedRates
+
+func (y yearlyRates) Rate(date time.Time, from, to Currency) (float64, error) {
+	rates, ok := y[date.Year()]
+	if !ok {
+		return 0, ErrNoExchangeRate
+	}
+	return rates.Rate(date, from, to)
+}
+
+func TestDecumulationTerminal(t *testing.T) {
+	date := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{
+		Accounts: map[string]Account{
+			"Checking":     NewBankAccount("Checking", date, 0),
+			"Brokerage":    NewBankAccount("Brokerage", date, Dollars(100000)),
+			"Euro Savings": NewCurrencyAccount("Euro Savings", date, Dollars(40000), CurrencyEUR),
+		},
+		FX: yearlyRates{2045: {CurrencyEUR: 0.8}, 2046: {CurrencyEUR: 1}},
+	}
+	d := &Decumulation{To: "Checking", Sources: []string{"Brokerage", "Euro Savings"}, Strategy: &FixedWithdrawal{Rate: 4}, StartDate: date, DayOfMonth: 1}
+
+	// The euros are valued at the rate of the day, so the portfolio is $150,000 and the first month is $500
+	if got := d.PortfolioValue(date, bank); got != Dollars(150000) {
+		t.Fatalf("portfolio = %s, want %s", got, Dollars(150000))
+	}
+	if err := d.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if d.Withdrawn != Dollars(500) {
+		t.Errorf("withdrawn = %s", d.Withdrawn)
+	}
+
+	// The terminal wealth is what is left after the withdrawal
+	if d.Terminal != Dollars(149500) {
+		t.Errorf("terminal = %s, want %s", d.Terminal, Dollars(149500))
+	}
+	next := date.AddDate(1, 0, 15)
+	if err := d.Process(next, bank); err != nil {
+		t.Fatal(err)
+	}
+	if d.Terminal != Dollars(139500) {
+		t.Errorf("terminal = %s at the %s rate, want %s", d.Terminal, next.Format("2006"), Dollars(139500))
+	}
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidExpression means an expression could not be evaluated
+var ErrInvalidExpression = errors.New("Invalid expression")
+
+// exprType is the type of an expression value
+type exprType string
+
+// Expression value types
+const (
+	exprNumber = exprType("number")
+	exprBool   = exprType("bool")
+	exprString = exprType("string")
+	exprPeriod = exprType("period")
+)
+
+// exprValue is the value of an expression. Numbers are dollars.
+type exprValue struct {
+	Num  float64
+	Bool bool
+	Str  string
+}
+
+// exprEnv is the simulation state an expression is evaluated against
+type exprEnv struct {
+	Date time.Time
+	Bank *Bank
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	Type() exprType
+	Eval(env exprEnv) (exprValue, error)
+}
+
+// exprFunc is a function which can be called from an expression
+type exprFunc struct {
+	Args    []exprType
+	Returns exprType
+	Call    func(env exprEnv, args []exprValue) (exprValue, error)
+}
+
+// exprVariables are the named constants of the expression language
+var exprVariables = map[string]exprLiteral{
+	"month": {exprPeriod, exprValue{Str: "month"}},
+	"year":  {exprPeriod, exprValue{Str: "year"}},
+	"true":  {exprBool, exprValue{Bool: true}},
+	"false": {exprBool, exprValue{Bool: false}},
+}
+
+// exprFuncs are the functions of the expression language
+var exprFuncs = map[string]exprFunc{
+	"balance": {[]exprType{exprString}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		balance, ok := accountBalance(env.Bank, args[0].Str)
+		if !ok {
+			return exprValue{}, ErrAccountDoesNotExist
+		}
+		return exprValue{Num: balance.Float64()}, nil
+	}},
+	"income": {[]exprType{exprString, exprPeriod}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: periodTotal(env, args[0].Str, args[1].Str, Deposit).Float64()}, nil
+	}},
+	"spending": {[]exprType{exprString, exprPeriod}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: periodTotal(env, args[0].Str, args[1].Str, Withdrawal).Float64()}, nil
+	}},
+	"min": {[]exprType{exprNumber, exprNumber}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: math.Min(args[0].Num, args[1].Num)}, nil
+	}},
+	"max": {[]exprType{exprNumber, exprNumber}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: math.Max(args[0].Num, args[1].Num)}, nil
+	}},
+	"abs": {[]exprType{exprNumber}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: math.Abs(args[0].Num)}, nil
+	}},
+	"round": {[]exprType{exprNumber}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: math.Round(args[0].Num)}, nil
+	}},
+	"if": {[]exprType{exprBool, exprNumber, exprNumber}, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		if args[0].Bool {
+			return args[1], nil
+		}
+		return args[2], nil
+	}},
+	"day": {nil, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: float64(env.Date.Day())}, nil
+	}},
+	"month": {nil, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: float64(env.Date.Month())}, nil
+	}},
+	"year": {nil, exprNumber, func(env exprEnv, args []exprValue) (exprValue, error) {
+		return exprValue{Num: float64(env.Date.Year())}, nil
+	}},
+}
+
+// periodTotal returns the total of the transactions of a type for a line item or category from the start of the
+// month or year through the date in US dollars. Transfers are not included.
+func periodTotal(env exprEnv, name, period string, kind TransactionType) USD {
+	start := time.Date(env.Date.Year(), env.Date.Month(), 1, 0, 0, 0, 0, env.Date.Location())
+	if period == "year" {
+		start = time.Date(env.Date.Year(), time.January, 1, 0, 0, 0, 0, env.Date.Location())
+	}
+
+	var total USD
+	for _, acct := range env.Bank.Accounts {
+		ledger := acct.Transactions()
+		for i := len(ledger) - 1; i >= 0 && !ledger[i].Date.Before(start); i-- {
+			tx := ledger[i]
+			if tx.Type != kind || tx.Category == CategoryTransfer || tx.Date.After(env.Date) {
+				continue
+			}
+			if tx.Description == name || tx.Category == name {
+				total += env.Bank.inUSD(tx.Date, acct, tx.Amount)
+			}
+		}
+	}
+	return total
+}
+
+// exprLiteral is a constant value
+type exprLiteral struct {
+	typ   exprType
+	value exprValue
+}
+
+func (n exprLiteral) Type() exprType {
+	return n.typ
+}
+
+func (n exprLiteral) Eval(env exprEnv) (exprValue, error) {
+	return n.value, nil
+}
+
+// exprCall is a function call
+type exprCall struct {
+	fn   exprFunc
+	args []exprNode
+}
+
+func (n *exprCall) Type() exprType {
+	return n.fn.Returns
+}
+
+func (n *exprCall) Eval(env exprEnv) (exprValue, error) {
+	args := make([]exprValue, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.Eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		args[i] = v
+	}
+	return n.fn.Call(env, args)
+}
+
+// exprUnary is a negation or logical not
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (n *exprUnary) Type() exprType {
+	return n.operand.Type()
+}
+
+func (n *exprUnary) Eval(env exprEnv) (exprValue, error) {
+	v, err := n.operand.Eval(env)
+	if err != nil {
+		return v, err
+	}
+	if n.op == "!" {
+		return exprValue{Bool: !v.Bool}, nil
+	}
+	return exprValue{Num: -v.Num}, nil
+}
+
+// exprBinary is an arithmetic, comparison or logical operation
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *exprBinary) Type() exprType {
+	switch n.op {
+	case "+", "-", "*", "/":
+		return exprNumber
+	}
+	return exprBool
+}
+
+func (n *exprBinary) Eval(env exprEnv) (exprValue, error) {
+	l, err := n.left.Eval(env)
+	if err != nil {
+		return l, err
+	}
+
+	// Logical operators short circuit
+	switch n.op {
+	case "&&":
+		if !l.Bool {
+			return l, nil
+		}
+		return n.right.Eval(env)
+	case "||":
+		if l.Bool {
+			return l, nil
+		}
+		return n.right.Eval(env)
+	}
+
+	r, err := n.right.Eval(env)
+	if err != nil {
+		return r, err
+	}
+
+	switch n.op {
+	case "+":
+		return exprValue{Num: l.Num + r.Num}, nil
+	case "-":
+		return exprValue{Num: l.Num - r.Num}, nil
+	case "*":
+		return exprValue{Num: l.Num * r.Num}, nil
+	case "/":
+		if r.Num == 0 {
+			return exprValue{}, fmt.Errorf("%v: division by zero", ErrInvalidExpression)
+		}
+		return exprValue{Num: l.Num / r.Num}, nil
+	case "<":
+		return exprValue{Bool: l.Num < r.Num}, nil
+	case "<=":
+		return exprValue{Bool: l.Num <= r.Num}, nil
+	case ">":
+		return exprValue{Bool: l.Num > r.Num}, nil
+	case ">=":
+		return exprValue{Bool: l.Num >= r.Num}, nil
+	case "==":
+		if n.left.Type() == exprBool {
+			return exprValue{Bool: l.Bool == r.Bool}, nil
+		}
+		return exprValue{Bool: l.Num == r.Num}, nil
+	case "!=":
+		if n.left.Type() == exprBool {
+			return exprValue{Bool: l.Bool != r.Bool}, nil
+		}
+		return exprValue{Bool: l.Num != r.Num}, nil
+	}
+	return exprValue{}, ErrInvalidExpression
+}
+
+// exprToken is a lexical token. Kind is one of number, string, ident or op.
+type exprToken struct {
+	kind string
+	text string
+	pos  int
+}
+
+// lexExpression splits the source into tokens.
+func lexExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.' || src[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{"number", strings.Replace(src[i:j], "_", "", -1), i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{"ident", src[i:j], i})
+			i = j
+		case c == '"':
+			j := strings.IndexByte(src[i+1:], '"')
+			if j < 0 {
+				return nil, fmt.Errorf("%v: unterminated string at %d", ErrInvalidExpression, i)
+			}
+			tokens = append(tokens, exprToken{"string", src[i+1 : i+1+j], i})
+			i += j + 2
+		default:
+			op := string(c)
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "<=", ">=", "==", "!=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/<>!(),", op) && len(op) == 1 {
+				return nil, fmt.Errorf("%v: unexpected '%s' at %d", ErrInvalidExpression, op, i)
+			}
+			tokens = append(tokens, exprToken{"op", op, i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// exprPrecedence is the binding power of the binary operators
+var exprPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6,
+}
+
+// exprParser is a precedence climbing parser which type checks the expression as it is parsed
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	pos := len(p.src)
+	if p.pos < len(p.tokens) {
+		pos = p.tokens[p.pos].pos
+	}
+	return fmt.Errorf("%v: %s at %d in '%s'", ErrInvalidExpression, fmt.Sprintf(format, args...), pos, p.src)
+}
+
+func (p *exprParser) peek() (exprToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return exprToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *exprParser) expect(op string) error {
+	if tok, ok := p.peek(); !ok || tok.kind != "op" || tok.text != op {
+		return p.errorf("expected '%s'", op)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseBinary(minPrec int) (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok := p.peek()
+		prec, isOp := exprPrecedence[tok.text]
+		if !ok || tok.kind != "op" || !isOp || prec < minPrec {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Type check the operands
+		want := exprNumber
+		switch tok.text {
+		case "&&", "||":
+			want = exprBool
+		case "==", "!=":
+			if left.Type() == exprBool {
+				want = exprBool
+			}
+		}
+		if left.Type() != want || right.Type() != want {
+			return nil, fmt.Errorf("%v: '%s' cannot be applied to %s and %s in '%s'", ErrInvalidExpression, tok.text, left.Type(), right.Type(), p.src)
+		}
+		left = &exprBinary{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok, ok := p.peek()
+	if ok && tok.kind == "op" && (tok.text == "-" || tok.text == "!") {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		want := exprNumber
+		if tok.text == "!" {
+			want = exprBool
+		}
+		if operand.Type() != want {
+			return nil, fmt.Errorf("%v: '%s' cannot be applied to %s in '%s'", ErrInvalidExpression, tok.text, operand.Type(), p.src)
+		}
+		return &exprUnary{op: tok.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	p.pos++
+
+	switch tok.kind {
+	case "number":
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("invalid number '%s'", tok.text)
+		}
+		return exprLiteral{exprNumber, exprValue{Num: v}}, nil
+	case "string":
+		return exprLiteral{exprString, exprValue{Str: tok.text}}, nil
+	case "ident":
+		if next, ok := p.peek(); ok && next.kind == "op" && next.text == "(" {
+			return p.parseCall(tok)
+		}
+		v, ok := exprVariables[tok.text]
+		if !ok {
+			p.pos--
+			return nil, p.errorf("unknown variable '%s'", tok.text)
+		}
+		return v, nil
+	case "op":
+		if tok.text == "(" {
+			node, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	}
+	p.pos--
+	return nil, p.errorf("unexpected '%s'", tok.text)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		p.pos--
+		return nil, p.errorf("unknown function '%s'", name.text)
+	}
+	p.pos++
+
+	var args []exprNode
+	if tok, ok := p.peek(); !ok || tok.kind != "op" || tok.text != ")" {
+		for {
+			arg, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if tok, ok := p.peek(); !ok || tok.kind != "op" || tok.text != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if len(args) != len(fn.Args) {
+		return nil, fmt.Errorf("%v: %s expects %d arguments but got %d in '%s'", ErrInvalidExpression, name.text, len(fn.Args), len(args), p.src)
+	}
+	for i, arg := range args {
+		if arg.Type() != fn.Args[i] {
+			return nil, fmt.Errorf("%v: argument %d of %s must be a %s not a %s in '%s'", ErrInvalidExpression, i+1, name.text, fn.Args[i], arg.Type(), p.src)
+		}
+	}
+	return &exprCall{fn: fn, args: args}, nil
+}
+
+// ParseExpression parses and type checks an expression. Numbers are in dollars.
+//
+// Expressions support arithmetic, comparisons and the logical operators && || and !, along with the
+// functions balance("Account"), income("Name", month|year) and spending("Name", month|year), which total
+// the line item or category for the month or year to date, min, max, abs, round, if(cond, a, b), and
+// day(), month() and year() of the current date.
+func ParseExpression(src string) (*Expression, error) {
+	tokens, err := lexExpression(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{src: src, tokens: tokens}
+	root, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	return &Expression{Source: src, root: root}, nil
+}
+
+// ParseAmount parses an expression which must evaluate to a dollar amount.
+func ParseAmount(src string) (*Expression, error) {
+	e, err := ParseExpression(src)
+	if err == nil && e.root.Type() != exprNumber {
+		return nil, fmt.Errorf("%v: '%s' is a %s not an amount", ErrInvalidExpression, src, e.root.Type())
+	}
+	return e, err
+}
+
+// ParseCondition parses an expression which must evaluate to true or false.
+func ParseCondition(src string) (*Expression, error) {
+	e, err := ParseExpression(src)
+	if err == nil && e.root.Type() != exprBool {
+		return nil, fmt.Errorf("%v: '%s' is a %s not a condition", ErrInvalidExpression, src, e.root.Type())
+	}
+	return e, err
+}
+
+// MustParseAmount parses an amount expression and panics if it is invalid.
+func MustParseAmount(src string) *Expression {
+	e, err := ParseAmount(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// MustParseCondition parses a condition expression and panics if it is invalid.
+func MustParseCondition(src string) *Expression {
+	e, err := ParseCondition(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Expression is a parsed expression over the simulation state. Condition expressions can be used as rule conditions.
+type Expression struct {
+	Source string
+	root   exprNode
+}
+
+// Amount evaluates the expression as a dollar amount rounded to the nearest cent.
+func (e *Expression) Amount(date time.Time, bank *Bank) (USD, error) {
+	if e.root.Type() != exprNumber {
+		return 0, ErrInvalidExpression
+	}
+	v, err := e.root.Eval(exprEnv{date, bank})
+	if err != nil {
+		return 0, err
+	}
+	return RoundHalfEven.Round(v.Num * 100), nil
+}
+
+// Scale returns an amount expression multiplied by the factor.
+func (e *Expression) Scale(factor float64) *Expression {
+	return &Expression{
+		Source: fmt.Sprintf("(%s) * %g", e.Source, factor),
+		root:   &exprBinary{op: "*", left: e.root, right: exprLiteral{typ: exprNumber, value: exprValue{Num: factor}}},
+	}
+}
+
+// Name returns the expression source.
+func (e *Expression) Name() string {
+	return e.Source
+}
+
+// Met evaluates the expression as a condition. Expressions which fail to evaluate are not met.
+func (e *Expression) Met(date time.Time, bank *Bank) bool {
+	if e.root.Type() != exprBool {
+		return false
+	}
+	v, err := e.root.Eval(exprEnv{date, bank})
+	if err != nil {
+		log.Println("ERR: ", err)
+		return false
+	}
+	return v.Bool
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpressionAmount(t *testing.T) {
+	date := time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", date.AddDate(0, -1, 0), Dollars(1000)),
+		")":        NewBankAccount(")", date, Dollars(42)),
+	}}
+	checking := bank.Accounts["Checking"]
+	checking.Append(Transaction{Date: date.AddDate(0, 0, -20), Type: Deposit, Description: "Salary", Amount: Dollars(3000)})
+	checking.Append(Transaction{Date: date.AddDate(0, 0, -5), Type: Deposit, Description: "Salary", Amount: Dollars(3000)})
+	checking.Append(Transaction{Date: date.AddDate(0, 0, -1), Type: Withdrawal, Category: "Dining", Amount: Dollars(40)})
+	checking.Append(Transaction{Date: date.AddDate(0, 0, -1), Type: Deposit, Category: CategoryTransfer, Description: "Salary", Amount: Dollars(500)})
+
+	tests := []struct {
+		src  string
+		want USD
+	}{
+		{"1 + 2 * 3", Dollars(7)},
+		{"(1 + 2) * 3", Dollars(9)},
+		{"10 - 4 - 3", Dollars(3)},
+		{"12 / 4 / 3", Dollars(1)},
+		{"-2 * -3", Dollars(6)},
+		{"1_000.50", 100050},
+		{"if(1 < 2 && !(2 < 1), 5, 6)", Dollars(5)},
+		{"if(1 > 2 || false, 5, 6)", Dollars(6)},
+		{"min(3, max(1, 2)) + abs(-4) + round(0.6)", Dollars(7)},
+		{"day() + month() + year()", Dollars(15 + 3 + 2018)},
+		{`balance("Checking")`, Dollars(1000 + 6000 - 40 + 500)},
+		{`income("Salary", month)`, Dollars(3000)},
+		{`income("Salary", year)`, Dollars(6000)},
+		{`spending("Dining", month)`, Dollars(40)},
+		{`balance(")")`, Dollars(42)},
+		{`min(balance(")"), 50)`, Dollars(42)},
+		{`0.10 * income("Salary", month)`, Dollars(300)},
+	}
+	for _, tt := range tests {
+		e, err := ParseAmount(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		got, err := e.Amount(date, bank)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+		} else if got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExpressionCondition(t *testing.T) {
+	date := time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{"Checking": NewBankAccount("Checking", date, Dollars(1000))}}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`balance("Checking") >= 1000`, true},
+		{`balance("Checking") > 1000`, false},
+		{"1 + 1 == 2", true},
+		{"true != false", true},
+		{"1 < 2 == 2 < 3", true},
+		{"false || true && false", false},
+		{`balance("Missing") > 0`, false},
+		{"1 / 0 > 0", false},
+	}
+	for _, tt := range tests {
+		e, err := ParseCondition(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got := e.Met(date, bank); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []string{
+		// Type errors
+		"1 + true",
+		"!1",
+		"-true",
+		"true < false",
+		`"Checking" + 1`,
+		`balance(1)`,
+		`income("Salary", "month")`,
+		"if(1, 2, 3)",
+
+		// Call arity
+		"min(1)",
+		"max(1, 2, 3)",
+		"day(1)",
+		`balance()`,
+
+		// Syntax
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"unknown(1)",
+		"unknown",
+		`balance(")"`,
+		`min(1 "," 2)`,
+		`"unterminated`,
+		"1 $ 2",
+		"1..2",
+	}
+	for _, src := range tests {
+		if _, err := ParseExpression(src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+
+	// Amounts and conditions must have the right type
+	if _, err := ParseAmount("1 < 2"); err == nil {
+		t.Error("condition parsed as an amount")
+	}
+	if _, err := ParseCondition("1 + 2"); err == nil {
+		t.Error("amount parsed as a condition")
+	}
+}
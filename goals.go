@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Goal is a financial goal which is evaluated at the end of each day.
+type Goal interface {
+	Name() string
+	Met(date time.Time, bank *Bank) bool
+}
+
+// BalanceGoal is met when the value of an account reaches the target amount.
+type BalanceGoal struct {
+	Account string
+	Amount  USD
+}
+
+func (g *BalanceGoal) Name() string {
+	return fmt.Sprintf("%s reaches %s", g.Account, g.Amount)
+}
+
+func (g *BalanceGoal) Met(date time.Time, bank *Bank) bool {
+	acct, ok := bank.Accounts[g.Account]
+	if !ok {
+		return false
+	}
+	return balanceInfo(date, g.Account, acct).AccountValue >= g.Amount
+}
+
+// PaidOffGoal is met when a loan has been paid off.
+type PaidOffGoal struct {
+	Loan string
+}
+
+func (g *PaidOffGoal) Name() string {
+	return fmt.Sprintf("%s paid off", g.Loan)
+}
+
+func (g *PaidOffGoal) Met(date time.Time, bank *Bank) bool {
+	loan, ok := bank.Accounts[g.Loan].(*LoanAccount)
+	return ok && loan.PayoffAmount() == 0
+}
+
+// EmergencyFundGoal is met when an account holds the given number of months of the average monthly
+// expenses paid from the expense account over the previous year. Transfers between the household's own
+// accounts are not expenses, but loan payments are.
+type EmergencyFundGoal struct {
+	Account  string
+	Expenses string
+	Months   int
+}
+
+func (g *EmergencyFundGoal) Name() string {
+	return fmt.Sprintf("Emergency fund of %d months expenses in %s", g.Months, g.Account)
+}
+
+func (g *EmergencyFundGoal) Met(date time.Time, bank *Bank) bool {
+	acct, ok := bank.Accounts[g.Account]
+	if !ok {
+		return false
+	}
+	expenses, ok := bank.Accounts[g.Expenses]
+	if !ok {
+		return false
+	}
+
+	// There is no expense history before the first month has passed
+	ledger := expenses.Transactions()
+	if len(ledger) == 0 || date.Before(ledger[0].Date.AddDate(0, 1, 0)) {
+		return false
+	}
+
+	start := date.AddDate(-1, 0, 0)
+	if start.Before(ledger[0].Date) {
+		start = ledger[0].Date
+	}
+
+	var total USD
+	for i := len(ledger) - 1; i >= 0 && ledger[i].Date.After(start); i-- {
+		if ledger[i].Type == Withdrawal && ledger[i].Category != CategoryTransfer {
+			total += ledger[i].Amount
+		}
+	}
+
+	months := date.Sub(start).Hours() / 24 / 365.25 * 12
+	monthly := total.Div(months, RoundHalfEven)
+	return acct.CurrentBalance() >= monthly*USD(g.Months)
+}
+
+// GoalResult is the date a goal was first met.
+type GoalResult struct {
+	Goal string
+	Met  bool
+	Date time.Time
+}
+
+// GoalResults are the results of the goals of a simulation run.
+type GoalResults []GoalResult
+
+// String returns the date each goal was met, or never.
+func (r GoalResults) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Goals\n")
+	for _, g := range r {
+		date := "never"
+		if g.Met {
+			date = g.Date.Format("2006-01-02")
+		}
+		fmt.Fprintf(&buf, "\t%-50s\t%s\n", g.Goal, date)
+	}
+	return buf.String()
+}
+
+// evaluateGoals records the date each goal is first met and emits a goal event.
+func (b *Bank) evaluateGoals(date time.Time) {
+	if b.goals == nil {
+		b.goals = map[string]time.Time{}
+	}
+
+	for _, goal := range b.Goals {
+		name := goal.Name()
+		if _, ok := b.goals[name]; ok {
+			continue
+		}
+		if goal.Met(date, b) {
+			b.goals[name] = date
+			b.Emit(date, "", "GOAL", name)
+		}
+	}
+}
+
+// GoalResults returns the date each goal was first met.
+func (b *Bank) GoalResults() GoalResults {
+	var results GoalResults
+	for _, goal := range b.Goals {
+		date, ok := b.goals[goal.Name()]
+		results = append(results, GoalResult{Goal: goal.Name(), Met: ok, Date: date})
+	}
+	return results
+}
+
+// GoalProbability is the share of simulation runs in which a goal was met.
+type GoalProbability struct {
+	Goal        string
+	Probability float64
+	Median      time.Time
+}
+
+// GoalProbabilities returns the probability of success of each goal across the results of many runs, along with
+// the median date the goal was met in the successful runs.
+func GoalProbabilities(runs []GoalResults) []GoalProbability {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	var probs []GoalProbability
+	for i, goal := range runs[0] {
+		var dates []time.Time
+		for _, run := range runs {
+			if i < len(run) && run[i].Met {
+				dates = append(dates, run[i].Date)
+			}
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+		p := GoalProbability{Goal: goal.Goal, Probability: float64(len(dates)) / float64(len(runs)) * 100}
+		if len(dates) > 0 {
+			p.Median = dates[len(dates)/2]
+		}
+		probs = append(probs, p)
+	}
+	return probs
+}
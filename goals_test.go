@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEmergencyFundGoal(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := NewLoan("Mortgage", Dollars(100000), 4, 30, 0)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", start, Dollars(20000)),
+		"Savings":  NewBankAccount("Savings", start, 0),
+		"Mortgage": loan,
+	}}
+	for month := 0; month < 12; month++ {
+		date := start.AddDate(0, month, 14)
+		if err := bank.Transfer(date, "Checking", "Savings", Dollars(500)); err != nil {
+			t.Fatal(err)
+		}
+		if err := bank.Transfer(date, "Checking", "Mortgage", loan.MonthlyPayment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The $6,000 in savings covers 12 months of the $477.42 mortgage payments but not 13. Transfers to
+	// savings are not expenses.
+	date := start.AddDate(1, 0, 0)
+	tests := []struct {
+		months int
+		met    bool
+	}{
+		{6, true},
+		{12, true},
+		{13, false},
+	}
+	for _, tt := range tests {
+		goal := &EmergencyFundGoal{Account: "Savings", Expenses: "Checking", Months: tt.months}
+		if met := goal.Met(date, bank); met != tt.met {
+			t.Errorf("%d months: met = %v, want %v", tt.months, met, tt.met)
+		}
+	}
+
+	// There is no expense history in the first month
+	goal := &EmergencyFundGoal{Account: "Savings", Expenses: "Checking", Months: 1}
+	if goal.Met(start.AddDate(0, 0, 20), bank) {
+		t.Error("goal met in the first month")
+	}
+}
+
+func TestGoalResults(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{
+		Accounts: map[string]Account{
+			"Checking": NewBankAccount("Checking", start, Dollars(900)),
+			"Loan":     NewLoan("Loan", Dollars(1000), 5, 1, 0),
+		},
+		Goals: []Goal{
+			&BalanceGoal{Account: "Checking", Amount: Dollars(1000)},
+			&PaidOffGoal{Loan: "Loan"},
+		},
+	}
+
+	bank.evaluateGoals(start)
+	deposit := start.AddDate(0, 0, 1)
+	bank.Accounts["Checking"].Append(Transaction{Date: deposit, Type: Deposit, Amount: Dollars(100)})
+	bank.evaluateGoals(deposit)
+
+	results := bank.GoalResults()
+	if !results[0].Met || !results[0].Date.Equal(deposit) {
+		t.Errorf("balance goal = %v %s", results[0].Met, results[0].Date)
+	}
+	if results[1].Met {
+		t.Errorf("loan paid off on %s", results[1].Date)
+	}
+}
+
+func TestGoalProbabilities(t *testing.T) {
+	date := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	runs := []GoalResults{
+		{{Goal: "Retire", Met: true, Date: date(2040)}, {Goal: "Millionaire"}},
+		{{Goal: "Retire"}, {Goal: "Millionaire"}},
+		{{Goal: "Retire", Met: true, Date: date(2030)}, {Goal: "Millionaire"}},
+	}
+
+	probs := GoalProbabilities(runs)
+	if len(probs) != 2 {
+		t.Fatalf("got %d goals, want 2", len(probs))
+	}
+
+	// The median of an even number of dates is the later date
+	if p := probs[0]; math.Abs(p.Probability-200./3) > 1e-9 || !p.Median.Equal(date(2040)) {
+		t.Errorf("retire = %.2f%% %s", p.Probability, p.Median)
+	}
+	if p := probs[1]; p.Probability != 0 || !p.Median.IsZero() {
+		t.Errorf("millionaire = %.2f%% %s", p.Probability, p.Median)
+	}
+	if GoalProbabilities(nil) != nil {
+		t.Error("probabilities without runs")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// LoanGrade describes the notes issued for a credit grade. Rates are annual percentages.
+type LoanGrade struct {
+	Grade   string
+	MinRate float64
+	MaxRate float64
+	Weight  float64
+	Risk    *CreditRisk
+}
+
+// LoanGrades is the list of grades notes are issued in.
+type LoanGrades []LoanGrade
+
+// DefaultLoanGrades are the A-G grades with their rate ranges, share of issued notes and credit risk.
+var DefaultLoanGrades = LoanGrades{
+	{Grade: "A", MinRate: 6, MaxRate: 9, Weight: 18, Risk: gradeRisk(2)},
+	{Grade: "B", MinRate: 9, MaxRate: 12, Weight: 30, Risk: gradeRisk(4)},
+	{Grade: "C", MinRate: 12, MaxRate: 16, Weight: 27, Risk: gradeRisk(7)},
+	{Grade: "D", MinRate: 16, MaxRate: 20, Weight: 14, Risk: gradeRisk(10)},
+	{Grade: "E", MinRate: 20, MaxRate: 24, Weight: 7, Risk: gradeRisk(14)},
+	{Grade: "F", MinRate: 24, MaxRate: 28, Weight: 3, Risk: gradeRisk(18)},
+	{Grade: "G", MinRate: 28, MaxRate: 31, Weight: 1, Risk: gradeRisk(22)},
+}
+
+// SixtyMonthShare is the percent of issued notes with a 60 month term instead of 36 months.
+var SixtyMonthShare = 30.
+
+func gradeRisk(annualDefaultRate float64) *CreditRisk {
+	risk := *DefaultCreditRisk
+	risk.AnnualDefaultRate = annualDefaultRate
+	return &risk
+}
+
+// CreditModel returns the credit risk of each grade.
+func (g LoanGrades) CreditModel() CreditModel {
+	model := CreditModel{}
+	for _, grade := range g {
+		model[grade.Grade] = grade.Risk
+	}
+	return model
+}
+
+// Listing generates a new note listing with a random grade, rate and term.
+func (g LoanGrades) Listing() NoteListing {
+	var total float64
+	for _, grade := range g {
+		total += grade.Weight
+	}
+
+	pick := rand.Float64() * total
+	grade := g[len(g)-1]
+	for _, gr := range g {
+		if pick < gr.Weight {
+			grade = gr
+			break
+		}
+		pick -= gr.Weight
+	}
+
+	term := 36
+	if rand.Float64() < SixtyMonthShare/100. {
+		term = 60
+	}
+	return NoteListing{
+		Grade: grade.Grade,
+		Rate:  grade.MinRate + rateBeta.Random()*(grade.MaxRate-grade.MinRate),
+		Term:  term,
+	}
+}
+
+// NoteListing is a note available for investment.
+type NoteListing struct {
+	Grade string
+	Rate  float64
+	Term  int
+}
+
+// InvestmentStrategy decides which listed notes an account buys.
+type InvestmentStrategy interface {
+	Accept(note NoteListing, acct *Peer2PeerAccount) bool
+}
+
+// GradeLimit caps the percent of outstanding principal invested in a group of grades.
+type GradeLimit struct {
+	Grades     []string
+	MaxPercent float64
+}
+
+// GradeStrategy buys notes in the allowed grades and terms while staying within the grade limits.
+// Empty grades or terms allow everything.
+type GradeStrategy struct {
+	Grades []string
+	Terms  []int
+	Limits []GradeLimit
+}
+
+// Accept returns true if the note should be bought.
+func (s *GradeStrategy) Accept(note NoteListing, acct *Peer2PeerAccount) bool {
+	if len(s.Grades) > 0 && !containsGrade(s.Grades, note.Grade) {
+		return false
+	}
+
+	if len(s.Terms) > 0 {
+		allowed := false
+		for _, term := range s.Terms {
+			allowed = allowed || term == note.Term
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	// Limits apply to the portfolio including the new note
+	total := acct.OutstandingPrincipal + acct.PerInvestment
+	for _, limit := range s.Limits {
+		if !containsGrade(limit.Grades, note.Grade) {
+			continue
+		}
+
+		invested := acct.PerInvestment
+		for _, grade := range limit.Grades {
+			invested += acct.GradePrincipal[grade]
+		}
+		if float64(invested) > float64(total)*limit.MaxPercent/100. {
+			return false
+		}
+	}
+	return true
+}
+
+func containsGrade(grades []string, grade string) bool {
+	for _, g := range grades {
+		if g == grade {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestListing(t *testing.T) {
+	ranges := map[string]LoanGrade{}
+	for _, grade := range DefaultLoanGrades {
+		ranges[grade.Grade] = grade
+	}
+
+	for i := 0; i < 1000; i++ {
+		note := DefaultLoanGrades.Listing()
+		grade, ok := ranges[note.Grade]
+		if !ok {
+			t.Fatalf("unknown grade %q", note.Grade)
+		}
+		if note.Rate < grade.MinRate || note.Rate > grade.MaxRate {
+			t.Errorf("grade %s rate %g outside %g-%g", note.Grade, note.Rate, grade.MinRate, grade.MaxRate)
+		}
+		if note.Term != 36 && note.Term != 60 {
+			t.Errorf("term = %d", note.Term)
+		}
+	}
+}
+
+func TestGradeStrategy(t *testing.T) {
+	acct := &Peer2PeerAccount{
+		PerInvestment:        Dollars(25),
+		OutstandingPrincipal: Dollars(1000),
+		GradePrincipal:       map[string]USD{"A": Dollars(800), "E": Dollars(150), "F": Dollars(50)},
+	}
+	strategy := &GradeStrategy{
+		Terms:  []int{36},
+		Limits: []GradeLimit{{Grades: []string{"E", "F", "G"}, MaxPercent: 20}},
+	}
+
+	tests := []struct {
+		note NoteListing
+		want bool
+	}{
+		{NoteListing{Grade: "A", Term: 36}, true},
+		{NoteListing{Grade: "A", Term: 60}, false},
+
+		// 200 + 25 of 1025 is over 20%
+		{NoteListing{Grade: "E", Term: 36}, false},
+	}
+	for _, tt := range tests {
+		if got := strategy.Accept(tt.note, acct); got != tt.want {
+			t.Errorf("Accept(%+v) = %v, want %v", tt.note, got, tt.want)
+		}
+	}
+
+	acct.GradePrincipal["E"] = Dollars(100)
+	if !strategy.Accept(NoteListing{Grade: "E", Term: 36}, acct) {
+		t.Errorf("175 of 1025 is within the limit")
+	}
+
+	strategy.Grades = []string{"B"}
+	if strategy.Accept(NoteListing{Grade: "A", Term: 36}, acct) {
+		t.Errorf("grade A is not allowed")
+	}
+}
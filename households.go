@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InterbankService is the name of the service which routes transfers between households.
+const InterbankService = "Interbank"
+
+// Message types handled by the interbank service
+const (
+	TypeInterbankTransfer = MessageType("InterbankTransfer")
+	TypeSettlement        = MessageType("Settlement")
+	TypeDayComplete       = MessageType("DayComplete")
+)
+
+// InterbankTransfer is money in transit from an account of one household to an account of another. The
+// amount is in the currency of the sending account.
+type InterbankTransfer struct {
+	Date          time.Time
+	FromHousehold string
+	From          string
+	ToHousehold   string
+	To            string
+	Amount        USD
+	Currency      Currency
+	Description   string
+	Category      string
+	Returned      bool
+}
+
+// returned returns the transfer to the sender.
+func (t InterbankTransfer) returned(date time.Time) InterbankTransfer {
+	return InterbankTransfer{
+		Date:          date,
+		FromHousehold: t.ToHousehold,
+		From:          t.To,
+		ToHousehold:   t.FromHousehold,
+		To:            t.From,
+		Amount:        t.Amount,
+		Currency:      t.Currency,
+		Description:   "Returned: " + t.Description,
+		Category:      t.Category,
+		Returned:      true,
+	}
+}
+
+// Settlement requests the transfers sent to a household before the date. The interbank service fills in
+// the transfers and closes Done once every household has finished the previous day.
+type Settlement struct {
+	Household string
+	Date      time.Time
+	Transfers []InterbankTransfer
+	Done      chan struct{}
+}
+
+// NewInterbank creates an interbank service for the named households.
+func NewInterbank(households ...string) *Interbank {
+	i := &Interbank{
+		days:    map[string]int{},
+		pending: map[string][]InterbankTransfer{},
+	}
+	for _, name := range households {
+		i.days[name] = 0
+	}
+	return i
+}
+
+// Interbank routes transfers between the banks of several households which run as separate processes.
+// Transfers sent during a day are deposited when the receiving household starts the next day. Households
+// wait for each other at the start of each day so that every run settles the same transfers on the same day.
+type Interbank struct {
+	mu       sync.Mutex
+	days     map[string]int
+	pending  map[string][]InterbankTransfer
+	settling []*Settlement
+}
+
+// Name returns the service name
+func (i *Interbank) Name() string {
+	return InterbankService
+}
+
+// Validate returns an error unless every household of the service has a bank. Settlements of the other
+// households wait forever for a household without a bank.
+func (i *Interbank) Validate(banks ...*Bank) error {
+	wired := map[string]bool{}
+	for _, bank := range banks {
+		if _, ok := i.days[bank.Name]; !ok {
+			return fmt.Errorf("%v: bank '%s' is not an interbank household", ErrUnknownHousehold, bank.Name)
+		}
+		wired[bank.Name] = true
+	}
+	for name := range i.days {
+		if !wired[name] {
+			return fmt.Errorf("%v: household '%s' has no bank", ErrUnknownHousehold, name)
+		}
+	}
+	return nil
+}
+
+// Process handles transfer, settlement and day complete messages from the household banks. Settlements
+// are completed once the households are ready rather than waited for, so Process never blocks.
+func (i *Interbank) Process(msg Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	switch msg.Type {
+	case TypeInterbankTransfer:
+		tx := msg.Value.(InterbankTransfer)
+
+		// Transfers to unknown households are returned to the sender
+		if _, ok := i.days[tx.ToHousehold]; !ok {
+			tx = tx.returned(tx.Date)
+		}
+		i.pending[tx.ToHousehold] = append(i.pending[tx.ToHousehold], tx)
+	case TypeDayComplete:
+		household := msg.Value.(string)
+		if _, ok := i.days[household]; ok {
+			i.days[household]++
+		}
+	case TypeSettlement:
+		i.settling = append(i.settling, msg.Value.(*Settlement))
+	}
+	i.settle()
+}
+
+// settle completes the settlements of households which are ready.
+func (i *Interbank) settle() {
+	var waiting []*Settlement
+	for _, s := range i.settling {
+		if !i.ready(s.Household) {
+			waiting = append(waiting, s)
+			continue
+		}
+
+		var pending []InterbankTransfer
+		for _, tx := range i.pending[s.Household] {
+			if tx.Date.Before(s.Date) {
+				s.Transfers = append(s.Transfers, tx)
+			} else {
+				pending = append(pending, tx)
+			}
+		}
+		i.pending[s.Household] = pending
+
+		// Transfers from different households arrive in any order
+		sort.SliceStable(s.Transfers, func(a, b int) bool {
+			if !s.Transfers[a].Date.Equal(s.Transfers[b].Date) {
+				return s.Transfers[a].Date.Before(s.Transfers[b].Date)
+			}
+			return s.Transfers[a].FromHousehold < s.Transfers[b].FromHousehold
+		})
+		close(s.Done)
+	}
+	i.settling = waiting
+}
+
+// ready returns true when every household has finished as many days as the given household.
+func (i *Interbank) ready(household string) bool {
+	for _, days := range i.days {
+		if days < i.days[household] {
+			return false
+		}
+	}
+	return true
+}
+
+// interbank returns true if the bank is a household connected to the interbank service.
+func (b *Bank) interbank(ctx context.Context) bool {
+	return b.Name != "" && HasService(ctx, InterbankService)
+}
+
+// TransferTo transfers money from an account to an account of another household through the interbank
+// service. The money is withdrawn immediately and sent at the end of the day, then deposited when the other
+// household starts the next day.
+func (b *Bank) TransferTo(date time.Time, from, household, to string, amount USD, desc, category string) error {
+	if b.Name == "" {
+		return ErrNoInterbank
+	}
+
+	account, ok := b.Accounts[from]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+
+	// Check available funds
+	if account.CurrentBalance() < amount {
+		return ErrInsufficientFunds
+	}
+
+	if desc == "" {
+		desc = fmt.Sprintf("Transfer from '%s/%s' to '%s/%s'", b.Name, from, household, to)
+	}
+	tx := Transaction{Date: date, Type: Withdrawal, Description: desc, Category: category, Amount: amount}
+	if !account.Validate(tx) {
+		return ErrInvalidTransfer
+	}
+	if err := account.Append(tx); err != nil {
+		return err
+	}
+
+	b.outbox = append(b.outbox, InterbankTransfer{
+		Date:          date,
+		FromHousehold: b.Name,
+		From:          from,
+		ToHousehold:   household,
+		To:            to,
+		Amount:        amount,
+		Currency:      accountCurrency(account),
+		Description:   desc,
+		Category:      category,
+	})
+	return nil
+}
+
+// send sends the day's transfers to the interbank service. Without the service the transfers are
+// returned to the sending accounts.
+func (b *Bank) send(ctx context.Context, date time.Time) {
+	for _, tx := range b.outbox {
+		if b.interbank(ctx) {
+			SendTo(ctx, InterbankService, Message{Timestamp: time.Now().UTC(), Type: TypeInterbankTransfer, Value: tx})
+			continue
+		}
+
+		log.Println("ERR: ", ErrNoInterbank)
+		b.Emit(date, tx.From, "RETURNED", fmt.Sprintf("Returned %s to %s: %s", tx.Amount, tx.ToHousehold, ErrNoInterbank))
+		if err := b.deposit(date, tx.returned(date)); err != nil {
+			log.Println("ERR: ", err)
+		}
+	}
+	b.outbox = nil
+}
+
+// settle deposits the transfers sent to the household before the date. Transfers which cannot be deposited
+// are returned to the sender.
+func (b *Bank) settle(ctx context.Context, date time.Time) {
+	s := &Settlement{Household: b.Name, Date: date, Done: make(chan struct{})}
+	SendTo(ctx, InterbankService, Message{Timestamp: time.Now().UTC(), Type: TypeSettlement, Value: s})
+
+	// Wait for the other households to finish the previous day
+	select {
+	case <-s.Done:
+	case <-ctx.Done():
+		return
+	}
+
+	for _, tx := range s.Transfers {
+		err := b.deposit(date, tx)
+		if err == nil {
+			continue
+		} else if tx.Returned {
+			log.Println("ERR: ", err)
+			continue
+		}
+
+		b.Emit(date, tx.To, "RETURNED", fmt.Sprintf("Returned %s from %s: %s", tx.Amount, tx.FromHousehold, err))
+		SendTo(ctx, InterbankService, Message{Timestamp: time.Now().UTC(), Type: TypeInterbankTransfer, Value: tx.returned(date)})
+	}
+}
+
+// deposit deposits a transfer from another household, converting it to the currency of the account.
+func (b *Bank) deposit(date time.Time, tx InterbankTransfer) error {
+	account, ok := b.Accounts[tx.To]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+
+	amount, err := b.Convert(date, tx.Amount, tx.Currency, accountCurrency(account))
+	if err != nil {
+		return err
+	}
+	return account.Append(Transaction{Date: date, Type: Deposit, Description: tx.Description, Category: tx.Category, Amount: amount})
+}
+
+// completeDay tells the interbank service the household has finished the day.
+func (b *Bank) completeDay(ctx context.Context) {
+	SendTo(ctx, InterbankService, Message{Timestamp: time.Now().UTC(), Type: TypeDayComplete, Value: b.Name})
+}
+
+// HouseholdTransfer is a monthly transfer to an account of another household, such as rent paid to a
+// landlord. A transfer with the same start and end date is made once.
+type HouseholdTransfer struct {
+	From       string
+	Household  string
+	To         string
+	Name       string
+	Category   string
+	Amount     USD
+	DayOfMonth int
+	StartDate  time.Time
+	EndDate    time.Time
+}
+
+func (h *HouseholdTransfer) Description() string {
+	return fmt.Sprintf("%20s\t%s to %s/%s\t%s", h.Name, h.From, h.Household, h.To, h.Amount)
+}
+
+func (h *HouseholdTransfer) Process(date time.Time, bank *Bank) error {
+	if date.Before(h.StartDate) || date.After(h.EndDate) || date.Day() != h.DayOfMonth {
+		return nil
+	}
+	return bank.TransferTo(date, h.From, h.Household, h.To, h.Amount, h.Name, h.Category)
+}
+
+// HouseholdLoanPayment pays a loan funded by another household. The payment is recorded on the loan
+// account and sent to the lender's account.
+type HouseholdLoanPayment struct {
+	From       string
+	Loan       string
+	Household  string
+	To         string
+	DayOfMonth int
+}
+
+func (l *HouseholdLoanPayment) Description() string {
+	return fmt.Sprintf("LOAN PAYMENT %s to %s/%s for %s", l.From, l.Household, l.To, l.Loan)
+}
+
+func (l *HouseholdLoanPayment) Process(date time.Time, bank *Bank) error {
+	if date.Day() != l.DayOfMonth {
+		return nil
+	}
+
+	acct, ok := bank.Accounts[l.Loan]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+	loan, ok := acct.(*LoanAccount)
+	if !ok {
+		return ErrInvalidTransfer
+	}
+
+	amount := loan.MonthlyPayment
+	if loan.MonthsPaid >= loan.Periods {
+		amount = loan.RemainingBalance
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	tx := Transaction{Date: date, Type: Deposit, Description: fmt.Sprintf("Payment to '%s/%s'", l.Household, l.To), Amount: amount}
+	if !loan.Validate(tx) {
+		return ErrInvalidTransfer
+	}
+	if err := bank.TransferTo(date, l.From, l.Household, l.To, amount, tx.Description, CategoryLoanPayment); err != nil {
+		return err
+	}
+	return loan.Append(tx)
+}
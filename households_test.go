@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterbankSettlement(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	startDate := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	household := &Bank{
+		Name:     "Household",
+		Accounts: map[string]Account{"Checking": NewBankAccount("Checking", startDate, Dollars(1000))},
+		LineItems: []LineItem{
+			&HouseholdTransfer{From: "Checking", Household: "Parents", To: "Checking", Name: "Rent", Amount: Dollars(100), DayOfMonth: 1, StartDate: startDate, EndDate: startDate},
+			&HouseholdTransfer{From: "Checking", Household: "Parents", To: "Savings", Name: "Gift", Amount: Dollars(50), DayOfMonth: 1, StartDate: startDate, EndDate: startDate},
+			&HouseholdTransfer{From: "Checking", Household: "Neighbors", To: "Checking", Name: "Loan", Amount: Dollars(25), DayOfMonth: 1, StartDate: startDate, EndDate: startDate},
+		},
+	}
+	parents := &Bank{
+		Name:     "Parents",
+		Accounts: map[string]Account{"Checking": NewBankAccount("Checking", startDate, 0)},
+	}
+
+	interbank := NewInterbank("Household", "Parents")
+	if err := interbank.Validate(household, parents); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = WithService(ctx, interbank)
+	householdProc := NewDefaultProcess(ctx, "Household Process", household, ProcessList{})
+	parentsProc := NewDefaultProcess(ctx, "Parents Process", parents, ProcessList{})
+
+	// Each day ends with the household and parents balances
+	expected := []struct {
+		household USD
+		parents   USD
+	}{
+		{Dollars(825), 0},
+		{Dollars(850), Dollars(100)},
+		{Dollars(900), Dollars(100)},
+	}
+	for i, e := range expected {
+		date := startDate.AddDate(0, 0, i)
+		household.Handle(ctx, householdProc, Message{Type: TypeDate, Value: date})
+		parents.Handle(ctx, parentsProc, Message{Type: TypeDate, Value: date})
+
+		if balance := household.Accounts["Checking"].CurrentBalance(); balance != e.household {
+			t.Errorf("%s: household balance %s, expected %s", date.Format("2006-01-02"), balance, e.household)
+		}
+		if balance := parents.Accounts["Checking"].CurrentBalance(); balance != e.parents {
+			t.Errorf("%s: parents balance %s, expected %s", date.Format("2006-01-02"), balance, e.parents)
+		}
+	}
+
+	// The transfer to an unknown household is returned the next day and the transfer to an unknown
+	// account the day after it is rejected
+	returned := map[string]time.Time{}
+	for _, tx := range household.Accounts["Checking"].Transactions() {
+		if strings.HasPrefix(tx.Description, "Returned: ") {
+			returned[tx.Description] = tx.Date
+		}
+	}
+	if date := returned["Returned: Loan"]; !date.Equal(startDate.AddDate(0, 0, 1)) {
+		t.Errorf("Loan returned on %s, expected %s", date, startDate.AddDate(0, 0, 1))
+	}
+	if date := returned["Returned: Gift"]; !date.Equal(startDate.AddDate(0, 0, 2)) {
+		t.Errorf("Gift returned on %s, expected %s", date, startDate.AddDate(0, 0, 2))
+	}
+}
+
+func TestInterbankValidate(t *testing.T) {
+	interbank := NewInterbank("Household", "Parents")
+	if err := interbank.Validate(&Bank{Name: "Household"}); err == nil || !strings.HasPrefix(err.Error(), ErrUnknownHousehold.Error()) {
+		t.Errorf("expected %v for a household without a bank, got %v", ErrUnknownHousehold, err)
+	}
+	if err := interbank.Validate(&Bank{Name: "Household"}, &Bank{Name: "Parents"}, &Bank{Name: "Neighbors"}); err == nil {
+		t.Errorf("expected %v for a bank which is not a household", ErrUnknownHousehold)
+	}
+	if err := interbank.Validate(&Bank{Name: "Household"}, &Bank{Name: "Parents"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransferToWithoutInterbank(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := (&Bank{}).TransferTo(date, "Checking", "Parents", "Checking", Dollars(100), "", ""); err != ErrNoInterbank {
+		t.Errorf("expected %v for an unnamed bank, got %v", ErrNoInterbank, err)
+	}
+
+	// Transfers made without the service are returned at the end of the day
+	ctx := context.Background()
+	bank := &Bank{
+		Name:     "Household",
+		Accounts: map[string]Account{"Checking": NewBankAccount("Checking", date, Dollars(1000))},
+		LineItems: []LineItem{
+			&HouseholdTransfer{From: "Checking", Household: "Parents", To: "Checking", Name: "Rent", Amount: Dollars(100), DayOfMonth: 1, StartDate: date, EndDate: date},
+		},
+	}
+	bank.Handle(ctx, NewDefaultProcess(ctx, "Bank Process", bank, ProcessList{}), Message{Type: TypeDate, Value: date})
+	if balance := bank.Accounts["Checking"].CurrentBalance(); balance != Dollars(1000) {
+		t.Errorf("balance %s, expected %s", balance, Dollars(1000))
+	}
+}
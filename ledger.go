@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Ledger returns the transactions of the given accounts, or every account if none are given,
+// ordered by date and account name. Amounts and balances of accounts in other currencies are
+// converted to US dollars.
+func (b *Bank) Ledger(accounts ...string) []AccountTransaction {
+	if len(accounts) == 0 {
+		accounts = b.names()
+	}
+
+	var entries []AccountTransaction
+	for _, name := range accounts {
+		acct, ok := b.Accounts[name]
+		if !ok {
+			continue
+		}
+		for _, tx := range ledgerTransactions(acct) {
+			tx.Amount = b.inUSD(tx.Date, acct, tx.Amount)
+			tx.Balance = b.inUSD(tx.Date, acct, tx.Balance)
+			entries = append(entries, AccountTransaction{Account: name, Transaction: tx})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Account < entries[j].Account
+		}
+		return entries[i].Date.Before(entries[j].Date)
+	})
+	return entries
+}
+
+// ledgerTransactions returns the transactions of an account for the ledger exports. A peer-to-peer account
+// records a transaction for every note payment, so transactions of the same day and type are combined.
+func ledgerTransactions(acct Account) []Transaction {
+	txs := acct.Transactions()
+	if _, ok := acct.(*Peer2PeerAccount); !ok {
+		return txs
+	}
+
+	var combined []Transaction
+	var count int
+	for _, tx := range txs {
+		last := len(combined) - 1
+		if last < 0 || !combined[last].Date.Equal(tx.Date) || combined[last].Type != tx.Type {
+			combined = append(combined, tx)
+			count = 1
+			continue
+		}
+
+		count++
+		combined[last].Description = fmt.Sprintf("%d %s transactions", count, strings.ToLower(string(tx.Type)))
+		combined[last].Amount += tx.Amount
+		combined[last].Balance = tx.Balance
+	}
+	return combined
+}
+
+// signedAmount returns the transaction amount signed by its effect on the account balance.
+// Loan balances are the principal owed, so payments are negative and interest charges are positive.
+func signedAmount(acct Account, tx Transaction) USD {
+	if _, ok := acct.(*LoanAccount); ok {
+		switch tx.Type {
+		case Deposit, Payoff:
+			return -tx.Amount
+		}
+		return tx.Amount
+	}
+
+	switch tx.Type {
+	case Withdrawal, NotePurchase:
+		return -tx.Amount
+	}
+	return tx.Amount
+}
+
+// ledgerBalance returns the current balance of an account as the ledger exports record it.
+func ledgerBalance(acct Account) USD {
+	if loan, ok := acct.(*LoanAccount); ok {
+		return loan.PayoffAmount()
+	}
+	return acct.CurrentBalance()
+}
+
+// ExportLedgerCSV writes the ledgers of the given accounts as CSV with the running balance of each account.
+func ExportLedgerCSV(w io.Writer, bank *Bank, accounts ...string) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "account", "type", "description", "amount", "balance"})
+	for _, entry := range bank.Ledger(accounts...) {
+		out.Write([]string{
+			entry.Date.Format("2006-01-02"),
+			entry.Account,
+			string(entry.Type),
+			entry.Description,
+			formatDollars(signedAmount(bank.Accounts[entry.Account], entry.Transaction)),
+			formatDollars(entry.Balance),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// qifType returns the QIF account type for an account.
+func qifType(acct Account) string {
+	switch acct.(type) {
+	case *LoanAccount:
+		return "Oth L"
+	case *PropertyAccount:
+		return "Oth A"
+	}
+	return "Bank"
+}
+
+// ExportQIF writes the ledgers of the given accounts in the Quicken Interchange Format. Like the other
+// ledger exports, amounts are in US dollars.
+func ExportQIF(w io.Writer, bank *Bank, accounts ...string) error {
+	if len(accounts) == 0 {
+		accounts = bank.names()
+	}
+
+	out := bufio.NewWriter(w)
+	for _, name := range accounts {
+		acct, ok := bank.Accounts[name]
+		if !ok {
+			return ErrAccountDoesNotExist
+		}
+
+		kind := qifType(acct)
+		fmt.Fprintf(out, "!Account\nN%s\nT%s\n^\n!Type:%s\n", name, kind, kind)
+		for _, entry := range bank.Ledger(name) {
+			fmt.Fprintf(out, "D%s\nT%s\nP%s\nM%s\n^\n",
+				entry.Date.Format("01/02/2006"),
+				formatDollars(signedAmount(acct, entry.Transaction)),
+				entry.Description,
+				entry.Type,
+			)
+		}
+	}
+	return out.Flush()
+}
+
+// ofxText escapes text for OFX elements, truncating it to the given number of characters.
+func ofxText(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max])
+	}
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// ExportOFX writes the ledgers of the given accounts as an OFX 2 bank statement for each account. Amounts
+// are in US dollars and the ledger balance of a loan is the principal owed.
+func ExportOFX(w io.Writer, bank *Bank, accounts ...string) error {
+	if len(accounts) == 0 {
+		accounts = bank.names()
+	}
+
+	entries := bank.Ledger(accounts...)
+	if len(entries) == 0 {
+		return nil
+	}
+	start := entries[0].Date.Format("20060102")
+	end := entries[len(entries)-1].Date.Format("20060102")
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	fmt.Fprintf(out, "<?OFX OFXHEADER=\"200\" VERSION=\"211\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	fmt.Fprintf(out, "<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", end)
+	fmt.Fprintf(out, "<BANKMSGSRSV1>\n")
+	for i, name := range accounts {
+		acct, ok := bank.Accounts[name]
+		if !ok {
+			return ErrAccountDoesNotExist
+		}
+
+		acctType := "CHECKING"
+		if _, ok := acct.(*LoanAccount); ok {
+			acctType = "CREDITLINE"
+		}
+
+		fmt.Fprintf(out, "<STMTTRNRS><TRNUID>%d</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", i+1)
+		fmt.Fprintf(out, "<STMTRS><CURDEF>%s</CURDEF><BANKACCTFROM><BANKID>BANKSIM</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>%s</ACCTTYPE></BANKACCTFROM>\n", CurrencyUSD, ofxText(name, 22), acctType)
+		fmt.Fprintf(out, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", start, end)
+
+		balance := bank.inUSD(entries[len(entries)-1].Date, acct, ledgerBalance(acct))
+		for j, entry := range bank.Ledger(name) {
+			tx := entry.Transaction
+			amount := signedAmount(acct, tx)
+			trnType := "CREDIT"
+			if amount < 0 {
+				trnType = "DEBIT"
+			}
+			fmt.Fprintf(out, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%d</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+				trnType,
+				tx.Date.Format("20060102"),
+				formatDollars(amount),
+				j+1,
+				ofxText(tx.Description, 32),
+				tx.Type,
+			)
+			balance = tx.Balance
+		}
+		fmt.Fprintf(out, "</BANKTRANLIST>\n<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n</STMTRS></STMTTRNRS>\n", formatDollars(balance), end)
+	}
+	fmt.Fprintf(out, "</BANKMSGSRSV1>\n</OFX>\n")
+	return out.Flush()
+}
+
+// WriteLedgers exports the ledgers of the given accounts, or every account if none are given, to CSV, QIF and
+// OFX files named with the given prefix.
+func WriteLedgers(bank *Bank, prefix string, accounts ...string) error {
+	exports := []struct {
+		ext    string
+		export func(io.Writer, *Bank, ...string) error
+	}{
+		{".csv", ExportLedgerCSV},
+		{".qif", ExportQIF},
+		{".ofx", ExportOFX},
+	}
+
+	for _, e := range exports {
+		f, err := os.Create(prefix + e.ext)
+		if err != nil {
+			return err
+		}
+		if err := e.export(f, bank, accounts...); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedAmount(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	checking := NewBankAccount("Checking", date, Dollars(10000))
+	loan := NewLoan("Mortgage", Dollars(100000), 4, 30, 0)
+	owed := loan.PayoffAmount()
+	investment := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	investNotes(investment, date, 4)
+
+	checking.Append(Transaction{Date: date, Type: Withdrawal, Amount: Dollars(500)})
+	loan.Append(Transaction{Date: date, Type: Deposit, Amount: loan.MonthlyPayment})
+	loan.Append(Transaction{Date: date, Type: Payoff, Amount: loan.PayoffAmount()})
+	cash := investment.AvailableCash
+	if err := investment.MicroLoans[0].chargeOff(date, investment, &CreditRisk{RecoveryRate: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The signed amounts add up to the running balance, which is the principal owed for loans
+	for name, acct := range map[string]Account{"Checking": checking, "Mortgage": loan} {
+		var balance USD
+		if acct == loan {
+			balance = owed
+		}
+		for _, tx := range acct.Transactions() {
+			if amount := signedAmount(acct, tx); balance+amount != tx.Balance {
+				t.Errorf("%s %s: %s + %s != %s", name, tx.Type, balance, amount, tx.Balance)
+			}
+			balance = tx.Balance
+		}
+	}
+
+	// The charge-off records the $2.50 recovered on the $25 note
+	last := investment.Ledger[len(investment.Ledger)-1]
+	if amount := signedAmount(investment, last); last.Type != ChargeOff || amount != 250 || investment.AvailableCash-cash != amount {
+		t.Errorf("charge-off = %s %s, cash change %s", last.Type, amount, investment.AvailableCash-cash)
+	}
+}
+
+func TestLedgerTransactions(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	for _, amount := range []USD{100, 200, 300} {
+		acct.AvailableCash += amount
+		acct.Append(Transaction{Date: date.AddDate(0, 0, 1), Type: MonthlyPayment, Amount: amount})
+	}
+	acct.Append(Transaction{Date: date.AddDate(0, 0, 1), Type: Withdrawal, Amount: Dollars(50)})
+
+	// Note payments of the same day are combined
+	txs := ledgerTransactions(acct)
+	if len(txs) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(txs))
+	}
+	if tx := txs[1]; tx.Amount != 600 || tx.Balance != Dollars(106) || tx.Description != "3 monthlypayment transactions" {
+		t.Errorf("payments = %s %s %q", tx.Amount, tx.Balance, tx.Description)
+	}
+}
+
+func TestExportLedgerCSVReconciles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := NewLoan("Mortgage", Dollars(30000), 5, 15, 0)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking":   NewBankAccount("Checking", date, Dollars(100000)),
+		"Investment": NewPeer2PeerAccount("Investment", date, Dollars(5000), Dollars(25)),
+		"Mortgage":   loan,
+	}}
+	proc := NewDefaultProcess(ctx, "Bank Process", bank, ProcessList{})
+
+	// New notes are bought, paid and charged off while the mortgage is paid and then paid off early
+	for day := 0; day < 400; day++ {
+		today := date.AddDate(0, 0, day)
+		if today.Day() == 2 {
+			if err := (&LoanPayment{From: "Checking", To: "Mortgage", DayOfMonth: 2}).Process(today, bank); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if day == 300 {
+			if err := (&EarlyPayoff{From: "Checking", Loan: "Mortgage"}).Process(today, bank); err != nil {
+				t.Fatal(err)
+			}
+		}
+		bank.Accounts["Investment"].Update(ctx, proc, bank, today)
+	}
+	if loan.PayoffAmount() != 0 {
+		t.Fatalf("mortgage not paid off, %s owed", loan.PayoffAmount())
+	}
+
+	var buf bytes.Buffer
+	if err := ExportLedgerCSV(&buf, bank); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every row moves the running balance of its account by its amount
+	cents := func(s string) USD {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return USD(math.Round(f * 100))
+	}
+	balances := map[string]USD{"Mortgage": Dollars(30000)}
+	counts := map[string]int{}
+	for _, row := range rows[1:] {
+		account, amount, balance := row[1], cents(row[4]), cents(row[5])
+		if balances[account]+amount != balance {
+			t.Errorf("%s %s %s %q: %s + %s != %s", row[0], account, row[2], row[3], balances[account], amount, balance)
+		}
+		balances[account] = balance
+		counts[account+" "+row[2]]++
+	}
+	for _, kind := range []string{"Investment NOTEPURCHASE", "Investment MONTHLYPAYMENT", "Mortgage INTEREST", "Mortgage PAYOFF"} {
+		if counts[kind] == 0 {
+			t.Errorf("no %s rows", kind)
+		}
+	}
+}
+
+func TestExportsInUSD(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{
+		Accounts: map[string]Account{
+			"Checking":     NewBankAccount("Checking", date, Dollars(1000)),
+			"Euro Savings": NewCurrencyAccount("Euro Savings", date, 0, CurrencyEUR),
+		},
+		FX: FixedRates{CurrencyEUR: 0.8},
+	}
+	if err := bank.Transfer(date, "Checking", "Euro Savings", Dollars(500)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The €400 deposit is exported as $500 in every format
+	var qif, ofx bytes.Buffer
+	if err := ExportQIF(&qif, bank, "Euro Savings"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportOFX(&ofx, bank, "Euro Savings"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(qif.String(), "\nT500.00\n") || strings.Contains(qif.String(), "400.00") {
+		t.Errorf("QIF amounts are not in dollars:\n%s", qif.String())
+	}
+	for _, want := range []string{"<CURDEF>USD</CURDEF>", "<TRNAMT>500.00</TRNAMT>", "<BALAMT>500.00</BALAMT>"} {
+		if !strings.Contains(ofx.String(), want) {
+			t.Errorf("OFX is missing %s:\n%s", want, ofx.String())
+		}
+	}
+}
+
+func TestOFXText(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"Groceries", 32, "Groceries"},
+		{"Crème brûlée", 5, "Crème"},
+		{"日本円の預金", 3, "日本円"},
+		{"Fish & Chips", 6, "Fish &amp;"},
+	}
+	for _, tt := range tests {
+		if got := ofxText(tt.text, tt.max); got != tt.want {
+			t.Errorf("ofxText(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/atgjack/prob"
+)
+
+// dailyProbability converts an annual percentage chance into the chance of occurring on any one day.
+func dailyProbability(annualRate float64) float64 {
+	return 1 - math.Pow(1-annualRate/100., 1./365.)
+}
+
+// JobLoss pays the income line items while employed. Each day there is a chance of losing the job, after
+// which the income is paused for a random number of months and the unemployment benefits, if any, are paid
+// for up to the given number of months.
+type JobLoss struct {
+	Account       string
+	Income        []LineItem
+	AnnualRate    float64
+	MinMonths     int
+	MaxMonths     int
+	Duration      prob.Beta
+	Benefits      LineItem
+	BenefitMonths int
+	EndDate       time.Time
+
+	unemployed      bool
+	unemployedUntil time.Time
+	benefitsUntil   time.Time
+}
+
+func (j *JobLoss) Description() string {
+	var items []string
+	for _, item := range j.Income {
+		items = append(items, strings.TrimSpace(item.Description()))
+	}
+	return fmt.Sprintf("JOB LOSS %.1f%% of %s", j.AnnualRate, strings.Join(items, ", "))
+}
+
+// Priority returns the income priority since the job loss pays the income line items.
+func (j *JobLoss) Priority() int {
+	return PriorityIncome
+}
+
+func (j *JobLoss) Process(date time.Time, bank *Bank) error {
+	if j.unemployed && !date.Before(j.unemployedUntil) {
+		j.unemployed = false
+		bank.Emit(date, j.Account, "EMPLOYED", "Returned to work")
+	}
+
+	// Jobs are not lost after the end date, such as a retirement date
+	working := j.EndDate.IsZero() || date.Before(j.EndDate)
+	if working && !j.unemployed && rand.Float64() < dailyProbability(j.AnnualRate) {
+		months := j.MinMonths + int(math.Round(j.Duration.Random()*float64(j.MaxMonths-j.MinMonths)))
+		j.unemployed = true
+		j.unemployedUntil = date.AddDate(0, months, 0)
+		j.benefitsUntil = date.AddDate(0, j.BenefitMonths, 0)
+		bank.Emit(date, j.Account, "JOB LOSS", fmt.Sprintf("Unemployed for %d months", months))
+	}
+
+	if j.unemployed {
+		if j.Benefits != nil && date.Before(j.benefitsUntil) {
+			return j.Benefits.Process(date, bank)
+		}
+		return nil
+	}
+
+	// Every income item is paid even if another fails
+	var errs []error
+	for _, item := range j.Income {
+		if err := item.Process(date, bank); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// joinErrors combines the errors of several line items into one. A single error is returned as is.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Raise increases the amounts of the income line items by a percentage every year on the given date. Each
+// year there is also a chance of a promotion with a larger increase. Raises stop at the end date, if any,
+// or once every income line item has ended.
+type Raise struct {
+	Account          string
+	Income           []*MonthlyTransaction
+	Percent          float64
+	Month            time.Month
+	Day              int
+	PromotionRate    float64
+	PromotionPercent float64
+	StartDate        time.Time
+	EndDate          time.Time
+}
+
+func (r *Raise) Description() string {
+	return fmt.Sprintf("RAISE %.1f%% on %s %d", r.Percent, r.Month, r.Day)
+}
+
+// Priority returns the income priority so that raises apply to income paid the same day.
+func (r *Raise) Priority() int {
+	return PriorityIncome
+}
+
+func (r *Raise) Process(date time.Time, bank *Bank) error {
+	if !date.After(r.StartDate) || date.Month() != r.Month || date.Day() != r.Day {
+		return nil
+	}
+	if (!r.EndDate.IsZero() && !date.Before(r.EndDate)) || !r.earning(date) {
+		return nil
+	}
+
+	percent, kind := r.Percent, "RAISE"
+	if rand.Float64() < r.PromotionRate/100. {
+		percent, kind = r.PromotionPercent, "PROMOTION"
+	}
+	if percent == 0 {
+		return nil
+	}
+
+	for _, item := range r.Income {
+		item.Amount = item.Amount.Mul(1+percent/100., RoundHalfEven)
+		if item.AmountExpr != nil {
+			item.AmountExpr = item.AmountExpr.Scale(1 + percent/100.)
+		}
+	}
+	bank.Emit(date, r.Account, kind, fmt.Sprintf("Income increased %.1f%%", percent))
+	return nil
+}
+
+// earning returns true if any of the income line items is still paid on the date.
+func (r *Raise) earning(date time.Time) bool {
+	for _, item := range r.Income {
+		if !date.After(item.EndDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// RandomExpense is a one-off expense such as a medical bill with an annual percentage chance of occurring
+// on any day. The amount is drawn between the base and maximum amounts.
+type RandomExpense struct {
+	Account    string
+	Name       string
+	Category   string
+	AnnualRate float64
+	BaseAmount USD
+	MaxAmount  USD
+	Beta       prob.Beta
+}
+
+func (r *RandomExpense) Description() string {
+	return fmt.Sprintf("%20s\t%.1f%%\t%s - %s", r.Name, r.AnnualRate, r.BaseAmount, r.MaxAmount)
+}
+
+func (r *RandomExpense) Process(date time.Time, bank *Bank) error {
+	if rand.Float64() >= dailyProbability(r.AnnualRate) {
+		return nil
+	}
+
+	amount := r.BaseAmount + (r.MaxAmount-r.BaseAmount).Mul(r.Beta.Random(), RoundHalfEven)
+	if err := bank.Append(r.Account, Transaction{Date: date, Type: Withdrawal, Description: r.Name, Category: r.Category, Amount: amount}); err != nil {
+		return err
+	}
+	bank.Emit(date, r.Account, "EXPENSE", fmt.Sprintf("%s of %s", r.Name, amount))
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atgjack/prob"
+)
+
+func TestJobLossPaysEveryIncome(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{"Checking": NewBankAccount("Checking", date, 0)}}
+	job := &JobLoss{
+		Account: "Checking",
+		Income: []LineItem{
+			&MonthlyTransaction{Account: "Missing", Name: "Bonus", Amount: Dollars(500), Type: Deposit, DayOfMonth: 1, StartDate: date, EndDate: date},
+			&MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(7000), Type: Deposit, DayOfMonth: 1, StartDate: date, EndDate: date},
+		},
+	}
+
+	// The salary is paid even though the bonus fails
+	if err := job.Process(date, bank); err != ErrAccountDoesNotExist {
+		t.Errorf("err = %v, want %v", err, ErrAccountDoesNotExist)
+	}
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(7000) {
+		t.Errorf("checking = %s", got)
+	}
+}
+
+func TestRandomExpenseEvent(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	beta, _ := prob.NewBeta(1, 4)
+	bank := &Bank{Accounts: map[string]Account{"Checking": NewBankAccount("Checking", date, Dollars(100))}}
+	expense := &RandomExpense{Account: "Checking", Name: "Medical", AnnualRate: 100, BaseAmount: Dollars(500), MaxAmount: Dollars(1000), Beta: beta}
+
+	// The expense is always drawn but cannot be paid, so there is no event
+	if err := expense.Process(date, bank); err == nil {
+		t.Error("expense paid without funds")
+	}
+	if len(bank.events) != 0 {
+		t.Errorf("events = %v", bank.events)
+	}
+
+	bank.Accounts["Checking"].Append(Transaction{Date: date, Type: Deposit, Amount: Dollars(1000)})
+	if err := expense.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if len(bank.events) != 1 || bank.events[0].Type != "EXPENSE" {
+		t.Errorf("events = %v", bank.events)
+	}
+}
+
+func TestRaise(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	retire := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	checking := NewBankAccount("Checking", start, 0)
+	bank := &Bank{Accounts: map[string]Account{"Checking": checking}}
+	salary := &MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(5000), Type: Deposit, DayOfMonth: 1, StartDate: start, EndDate: retire}
+	bonus := &MonthlyTransaction{Account: "Checking", Name: "Bonus", AmountExpr: MustParseAmount("100 * month()"), Type: Deposit, DayOfMonth: 1, StartDate: start, EndDate: retire}
+	raise := &Raise{Account: "Checking", Income: []*MonthlyTransaction{salary, bonus}, Percent: 10, Month: time.January, Day: 1, StartDate: start, EndDate: retire}
+
+	// There is no raise on the start date and none from the retirement date
+	for year := 2018; year <= 2023; year++ {
+		if err := raise.Process(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), bank); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if salary.Amount != Dollars(6050) {
+		t.Errorf("salary = %s, want %s", salary.Amount, Dollars(6050))
+	}
+	if len(bank.events) != 2 {
+		t.Errorf("%d raises, want 2", len(bank.events))
+	}
+
+	// Amount expressions are raised as well
+	bonusAmount, err := bonus.AmountExpr.Amount(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), bank)
+	if err != nil || bonusAmount != Dollars(363) {
+		t.Errorf("bonus = %s (%s), want %s: %v", bonusAmount, bonus.AmountExpr.Source, Dollars(363), err)
+	}
+
+	// Without an end date raises stop once every income item has ended
+	raise.EndDate = time.Time{}
+	if err := raise.Process(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), bank); err != nil || salary.Amount != Dollars(6050) {
+		t.Errorf("salary = %s after the income ended: %v", salary.Amount, err)
+	}
+	salary.EndDate = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := raise.Process(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), bank); err != nil || salary.Amount != Dollars(6655) {
+		t.Errorf("salary = %s while still paid: %v", salary.Amount, err)
+	}
+}
@@ -21,6 +21,21 @@ const (
 
 	// MonthlyPayment represents the payments from loans
 	MonthlyPayment TransactionType = "MONTHLYPAYMENT"
+
+	// Payoff represents paying off the remaining principal of a loan
+	Payoff TransactionType = "PAYOFF"
+
+	// NoteSale represents selling a note on the secondary market
+	NoteSale TransactionType = "NOTESALE"
+
+	// NotePurchase represents investing in a new note or buying a note on the secondary market
+	NotePurchase TransactionType = "NOTEPURCHASE"
+
+	// ChargeOff represents the principal recovered from a defaulted loan. The rest of the principal is lost
+	ChargeOff TransactionType = "CHARGEOFF"
+
+	// InterestCharge represents the interest charged on a loan ahead of each payment
+	InterestCharge TransactionType = "INTEREST"
 )
 
 // Transaction represents a monetary transaction
@@ -28,7 +43,11 @@ type Transaction struct {
 	Date        time.Time
 	Type        TransactionType
 	Description string
+	Category    string
 	Amount      USD
+
+	// Balance is the account balance after the transaction was posted
+	Balance USD
 }
 
 func (txn Transaction) String() string {
@@ -41,18 +60,36 @@ type LineItem interface {
 	Process(date time.Time, bank *Bank) error
 }
 
+// evalAmount returns the fixed amount, or the amount expression evaluated on the date if there is one.
+func evalAmount(amount USD, expr *Expression, date time.Time, bank *Bank) (USD, error) {
+	if expr == nil {
+		return amount, nil
+	}
+	return expr.Amount(date, bank)
+}
+
+// amountString returns the fixed amount or the amount expression.
+func amountString(amount USD, expr *Expression) string {
+	if expr == nil {
+		return amount.String()
+	}
+	return expr.Source
+}
+
 type MonthlyTransaction struct {
 	Account    string
 	Name       string
+	Category   string
 	Type       TransactionType
 	Amount     USD
+	AmountExpr *Expression
 	DayOfMonth int
 	StartDate  time.Time
 	EndDate    time.Time
 }
 
 func (m *MonthlyTransaction) Description() string {
-	return fmt.Sprintf("%20s\t%s", m.Name, m.Amount)
+	return fmt.Sprintf("%20s\t%s", m.Name, amountString(m.Amount, m.AmountExpr))
 }
 
 func (m *MonthlyTransaction) Process(date time.Time, bank *Bank) error {
@@ -61,7 +98,11 @@ func (m *MonthlyTransaction) Process(date time.Time, bank *Bank) error {
 	}
 
 	if date.After(m.StartDate) || date.Equal(m.StartDate) {
-		return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Amount: m.Amount})
+		amount, err := evalAmount(m.Amount, m.AmountExpr, date, bank)
+		if err != nil || amount <= 0 {
+			return err
+		}
+		return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Category: m.Category, Amount: amount})
 	}
 	return nil
 }
@@ -70,13 +111,14 @@ type MonthlyTransfer struct {
 	From       string
 	To         string
 	Amount     USD
+	AmountExpr *Expression
 	DayOfMonth int
 	StartDate  time.Time
 	EndDate    time.Time
 }
 
 func (m *MonthlyTransfer) Description() string {
-	return fmt.Sprintf("TRANSFER %s to %s\t%s", m.From, m.To, m.Amount)
+	return fmt.Sprintf("TRANSFER %s to %s\t%s", m.From, m.To, amountString(m.Amount, m.AmountExpr))
 }
 
 func (m *MonthlyTransfer) Process(date time.Time, bank *Bank) error {
@@ -85,17 +127,22 @@ func (m *MonthlyTransfer) Process(date time.Time, bank *Bank) error {
 	}
 
 	if date.After(m.StartDate) || date.Equal(m.StartDate) {
-		return bank.Transfer(date, m.From, m.To, m.Amount)
+		amount, err := evalAmount(m.Amount, m.AmountExpr, date, bank)
+		if err != nil || amount <= 0 {
+			return err
+		}
+		return bank.Transfer(date, m.From, m.To, amount)
 	}
 	return nil
 }
 
 type OneTimeTransaction struct {
-	Account string
-	Name    string
-	Type    TransactionType
-	Amount  USD
-	Date    time.Time
+	Account  string
+	Name     string
+	Category string
+	Type     TransactionType
+	Amount   USD
+	Date     time.Time
 }
 
 func (m *OneTimeTransaction) Description() string {
@@ -106,12 +153,13 @@ func (m *OneTimeTransaction) Process(date time.Time, bank *Bank) error {
 	if !equalDates(date, m.Date) {
 		return nil
 	}
-	return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Amount: m.Amount})
+	return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Category: m.Category, Amount: m.Amount})
 }
 
 type DailyRandomTransaction struct {
 	Account     string
 	Name        string
+	Category    string
 	Type        TransactionType
 	BaseAmount  USD
 	MaxAmount   USD
@@ -134,8 +182,8 @@ func (m *DailyRandomTransaction) Process(date time.Time, bank *Bank) error {
 		perc, ok := m.Percentages[date.Weekday()]
 		if ok {
 			if rand.Float64() < perc {
-				amount := m.BaseAmount + USD(m.Beta.Random()*float64(m.MaxAmount-m.BaseAmount))
-				return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Amount: amount})
+				amount := m.BaseAmount + (m.MaxAmount-m.BaseAmount).Mul(m.Beta.Random(), RoundHalfEven)
+				return bank.Append(m.Account, Transaction{Date: date, Type: m.Type, Description: m.Name, Category: m.Category, Amount: amount})
 			}
 		}
 	}
@@ -13,36 +13,27 @@ import (
 func NewLoan(name string, P USD, apr float64, years int, payments int) *LoanAccount {
 	periods := years * 12.
 	r := apr / 100. / 12.
-	rP := r * P.Float64()
 	rN := math.Pow(1+r, -float64(periods))
-	c := (rP / (1 - rN))
-	// log.Println(c)
+	payment := P.Mul(r/(1-rN), RoundHalfEven)
 	a := &LoanAccount{
 		Name:                name,
 		LoanAmount:          P,
 		Periods:             periods,
-		MonthlyPayment:      USD(math.Round(c * 100)),
+		MonthlyPayment:      payment,
 		MonthlyInterestRate: r,
 		InterestRate:        apr,
 		InterestPaid:        0,
 		PrincipalPaid:       0,
 		MonthsPaid:          1,
-		RemainingBalance:    USD(math.Round(c * float64(periods) * 100)),
+		RemainingBalance:    payment * USD(periods),
 		Ledger:              []Transaction{},
 	}
 	log.Println(a)
 
 	// Starting month adjustment
 	if payments > 0 {
-		a.RemainingBalance -= USD(int64(a.MonthlyPayment) * int64(payments))
-
-		// Cumulative interest
-		interest := (rP-c)*(math.Pow(1+r, float64(payments))-1)/r + c*float64(payments)
-		a.InterestPaid = USD(math.Round(interest * 100))
-
-		// Cumulative principal
-		principal := c*float64(payments) - interest
-		a.PrincipalPaid = USD(math.Round(principal * 100))
+		a.RemainingBalance -= a.MonthlyPayment * USD(payments)
+		a.InterestPaid, a.PrincipalPaid = a.cumulative(payments)
 		a.MonthsPaid += payments
 	}
 	log.Println(a)
@@ -64,6 +55,14 @@ type LoanAccount struct {
 	Ledger              []Transaction
 }
 
+// cumulative returns the interest and principal paid by the first n monthly payments. The principal is
+// the rest of the payments so that the two always add up to the amount paid.
+func (a *LoanAccount) cumulative(n int) (interest, principal USD) {
+	P, r, c := float64(a.LoanAmount), a.MonthlyInterestRate, float64(a.MonthlyPayment)
+	interest = RoundHalfEven.Round((P*r-c)*(math.Pow(1+r, float64(n))-1)/r + c*float64(n))
+	return interest, a.MonthlyPayment*USD(n) - interest
+}
+
 // Update allows for the account to update account information periodically.
 func (a *LoanAccount) Update(ctx context.Context, proc Process, bank *Bank, date time.Time) {
 }
@@ -73,6 +72,11 @@ func (a *LoanAccount) CurrentBalance() USD {
 	return a.RemainingBalance
 }
 
+// Transactions returns the account ledger
+func (a *LoanAccount) Transactions() []Transaction {
+	return a.Ledger
+}
+
 // Append appends a transaction to the account
 func (a *LoanAccount) Append(tx Transaction) error {
 	log.Println(a.Name, tx)
@@ -83,31 +87,46 @@ func (a *LoanAccount) Append(tx Transaction) error {
 	// log.Println(date.Format("2006/01/02"), item.Description())
 	if tx.Type == Deposit {
 		// log.Println(a)
+		owed, paid := a.LoanAmount-a.PrincipalPaid, a.PrincipalPaid
 
 		if a.MonthsPaid < a.Periods {
-			a.Ledger = append(a.Ledger, tx)
 			a.RemainingBalance -= a.MonthlyPayment
-
-			i := a.MonthsPaid
-			P, r, c := a.LoanAmount.Float64(), a.MonthlyInterestRate, a.MonthlyPayment.Float64()
-
-			// Cumulative interest
-			interest := (P*r-c)*(math.Pow(1+r, float64(i))-1)/r + c*float64(i)
-			a.InterestPaid = USD(math.Round(interest * 100))
-
-			// Cumulative principal
-			principal := c*float64(i) - interest
-			a.PrincipalPaid = USD(math.Round(principal * 100))
+			a.InterestPaid, a.PrincipalPaid = a.cumulative(a.MonthsPaid)
 		} else {
+			// The final payment pays off the remaining principal and the rest is interest
+			principal := a.LoanAmount - a.PrincipalPaid
+			if principal > tx.Amount {
+				principal = tx.Amount
+			} else if principal < 0 {
+				principal = 0
+			}
 			a.RemainingBalance -= tx.Amount
-			a.PrincipalPaid += tx.Amount
+			a.PrincipalPaid += principal
+			a.InterestPaid += tx.Amount - principal
 		}
 		a.MonthsPaid++
 		// log.Println(a)
 
+		// The interest in the payment is charged first so the ledger balance is the principal owed
+		if interest := tx.Amount - (a.PrincipalPaid - paid); interest != 0 {
+			a.Ledger = append(a.Ledger, Transaction{
+				Date:        tx.Date,
+				Type:        InterestCharge,
+				Description: "Interest charged",
+				Category:    tx.Category,
+				Amount:      interest,
+				Balance:     owed + interest,
+			})
+		}
+	} else if tx.Type == Payoff {
+		a.PrincipalPaid += tx.Amount
+		a.RemainingBalance = 0
+		a.MonthsPaid = a.Periods
 	} else {
 		return ErrUnknownTransactionType
 	}
+	tx.Balance = a.PayoffAmount()
+	a.Ledger = append(a.Ledger, tx)
 	return nil
 }
 
@@ -120,10 +139,20 @@ func (a *LoanAccount) Validate(tx Transaction) bool {
 		} else if a.RemainingBalance > 0 {
 			return true
 		}
+	} else if tx.Type == Payoff {
+		return a.RemainingBalance > 0
 	}
 	return false
 }
 
+// PayoffAmount returns the principal required to pay off the loan.
+func (a *LoanAccount) PayoffAmount() USD {
+	if a.RemainingBalance <= 0 {
+		return 0
+	}
+	return a.LoanAmount - a.PrincipalPaid
+}
+
 // String returns the string representation of the account
 func (a *LoanAccount) String() string {
 	return fmt.Sprintf("%s\t%s\n\t- %s\t%s\n\t- %s\t%d\n\t- %s\t%.3f%%\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n",
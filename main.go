@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"sync"
 	"time"
@@ -12,38 +15,306 @@ import (
 )
 
 func main() {
+	format := flag.String("format", "csv", "output format for balances and transactions: csv, jsonl or sqlite")
+	runs := flag.Int("runs", 0, "number of Monte Carlo runs drawn as net worth percentiles in the report")
+	seed := flag.Int64("seed", 0, "seed for the random number generator, 0 for a seed from the current time")
+	flag.Parse()
 	log.SetOutput(os.Stdout)
 
+	// Runs with the same seed are the same
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rand.Seed(*seed)
+	log.Println("Seed:", *seed)
+
 	startDate := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
-	endDate := startDate.AddDate(31, 0, 0)
+	endDate := startDate.AddDate(41, 0, 0)
+	retireDate := time.Date(2045, 1, 1, 0, 0, 0, 0, time.UTC)
 	// endDate := time.Date(2036, 1, 1, 0, 0, 0, 0, time.UTC)
 
+	s := newScenario(startDate, endDate, retireDate)
+	bank, parents, investment, decumulation := s.bank, s.parents, s.investment, s.decumulation
+
+	// Households transfer money to each other through the interbank service
+	interbank := NewInterbank("Household", "Parents")
+	if err := interbank.Validate(bank, parents); err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = WithService(ctx, interbank)
+
+	budget := Budget{
+		{LineItem: "Salary", Type: Deposit, Amount: Dollars(14000), EndDate: retireDate.AddDate(0, 0, -1)},
+		{Category: "Insurance", Amount: Dollars(630)},
+		{Category: "Utilities", Amount: Dollars(315)},
+		{Category: "Dining", Amount: Dollars(400)},
+	}
+
+	propertyOutput, err := os.Create("property.csv")
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer propertyOutput.Close()
+
+	portfolioOutput, err := os.Create("portfolio_monthly.csv")
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer portfolioOutput.Close()
+
+	propertySink := NewPropertySink(propertyOutput)
+
+	// Balances and transactions are written to daily and monthly CSV files, a JSON Lines file or a SQLite database
+	var sinks []Sink
+	var outputs ProcessList
+	switch *format {
+	case "csv":
+		dailyOutput, err := os.Create("daily.csv")
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer dailyOutput.Close()
+
+		monthlyOutput, err := os.Create("monthly.csv")
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer monthlyOutput.Close()
+
+		dailySink, err := NewCSVSink(dailyOutput, nil, nil, PeriodDaily, DefaultColumns...)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		monthlySink, err := NewCSVSink(monthlyOutput, nil, nil, PeriodMonthly, DefaultColumns...)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		sinks = append(sinks, monthlySink, dailySink)
+		outputs = append(outputs,
+			NewDefaultProcess(ctx, "Monthly Output", &SinkOutput{monthlySink}, ProcessList{}),
+			NewDefaultProcess(ctx, "Daily Output", &SinkOutput{dailySink}, ProcessList{}),
+		)
+	case "jsonl":
+		jsonOutput, err := os.Create("simulation.jsonl")
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		defer jsonOutput.Close()
+		jsonSink := NewJSONLinesSink(jsonOutput)
+		sinks = append(sinks, jsonSink)
+		outputs = append(outputs, NewDefaultProcess(ctx, "JSON Output", &SinkOutput{jsonSink}, ProcessList{}))
+	case "sqlite":
+		os.Remove("simulation.db")
+		sqliteSink, err := NewSQLiteSink("simulation.db")
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		sinks = append(sinks, sqliteSink)
+		outputs = append(outputs, NewDefaultProcess(ctx, "SQLite Output", &SinkOutput{sqliteSink}, ProcessList{}))
+	default:
+		log.Fatalf("unknown output format %q", *format)
+		return
+	}
+
+	reportOutput, err := os.Create("report.html")
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer reportOutput.Close()
+	report := NewHTMLReport(reportOutput, "Bank Simulation", "Checking")
+	summary := NewSummaryReport(os.Stdout)
+	parentsSummary := NewSummaryReport(os.Stdout)
+
+	outputs = append(outputs,
+		NewDefaultProcess(ctx, "Property Output", &SinkOutput{propertySink}, ProcessList{}),
+		NewDefaultProcess(ctx, "Portfolio Output", &PortfolioOutput{portfolioOutput}, ProcessList{}),
+		NewDefaultProcess(ctx, "Report Output", &SinkOutput{report}, ProcessList{}),
+		NewDefaultProcess(ctx, "Summary Output", &SinkOutput{summary}, ProcessList{}),
+	)
+
+	var wg sync.WaitGroup
+	engine := NewEngine(ctx, cancel, ProcessList{
+		NewDefaultProcess(ctx, "Date Process", &DayGenerator{startDate, endDate}, ProcessList{
+			NewDefaultProcess(ctx, "Bank Process", bank, outputs),
+			NewDefaultProcess(ctx, "Parents Process", parents, ProcessList{
+				NewDefaultProcess(ctx, "Parents Summary Output", &SinkOutput{parentsSummary}, ProcessList{}),
+			}),
+		}),
+	})
+	engine.Start(&wg)
+
+	wg.Wait()
+
+	// Other runs of the scenario are drawn as net worth percentiles
+	netWorth, goals := monteCarlo(*runs, startDate, endDate, retireDate)
+	report.Runs = netWorth
+
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Println("ERR: ", err)
+		}
+	}
+	if err := propertySink.Close(); err != nil {
+		log.Println("ERR: ", err)
+	}
+	if err := report.Close(); err != nil {
+		log.Println("ERR: ", err)
+	}
+
+	fmt.Println()
+	if err := summary.Close(); err != nil {
+		log.Println("ERR: ", err)
+	}
+	fmt.Println("\nParents")
+	if err := parentsSummary.Close(); err != nil {
+		log.Println("ERR: ", err)
+	}
+	fmt.Println()
+	fmt.Println(bank.Returns)
+	fmt.Println(bank.GoalResults())
+	if len(goals) > 0 {
+		fmt.Println("Goal probabilities")
+		for _, p := range GoalProbabilities(append(goals, bank.GoalResults())) {
+			median := "never"
+			if p.Probability > 0 {
+				median = p.Median.Format("2006-01-02")
+			}
+			fmt.Printf("\t%-50s\t%5.1f%%\t%s\n", p.Goal, p.Probability, median)
+		}
+		fmt.Println()
+	}
+	fmt.Println(decumulation)
+
+	budgetReport := budget.Report(bank, "Checking")
+	fmt.Println(budgetReport)
+	if budgetOutput, err := os.Create("budget.csv"); err != nil {
+		log.Println("ERR: ", err)
+	} else {
+		budgetReport.WriteCSV(budgetOutput)
+		budgetOutput.Close()
+	}
+
+	if err := WriteLedgers(bank, "ledger", "Checking", "Mortgage", "Family Loan", "Home", "Investment"); err != nil {
+		log.Println("ERR: ", err)
+	}
+
+	if err := WritePortfolio(endDate, investment, "portfolio.csv", "losses.csv"); err != nil {
+		log.Println("ERR: ", err)
+	}
+	fmt.Println("\nExiting...")
+}
+
+// scenario is the simulated household along with the parents' household it shares the interbank service with.
+type scenario struct {
+	bank         *Bank
+	parents      *Bank
+	investment   *Peer2PeerAccount
+	decumulation *Decumulation
+}
+
+// newScenario creates the accounts and line items of both households. Line items keep state between days, so
+// every run needs a new scenario.
+func newScenario(startDate, endDate, retireDate time.Time) *scenario {
 	// Create the LineItem types (asset, expense/liability, monthly, daily)
 	// Create the data processors and bank service
 
 	foodBeta, _ := prob.NewBeta(1, 4)
+	unemploymentBeta, _ := prob.NewBeta(2, 5)
+	medicalBeta, _ := prob.NewBeta(1, 6)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	salary := []*MonthlyTransaction{
+		&MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(7000), Type: Deposit, DayOfMonth: 1, StartDate: startDate, EndDate: retireDate},
+		&MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(7000), Type: Deposit, DayOfMonth: 15, StartDate: startDate, EndDate: retireDate},
+	}
+
+	retirement := NewRetirementAccount("401k", startDate, Dollars(45000), PreTax, time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC))
+	retirement.ReturnRate = 6
+	retirement.WithholdingRate = 20
+	retirement.RMDTo = "Checking"
+
+	// The investment account keeps some cash for withdrawals in retirement
+	investment := NewPeer2PeerAccount("Investment", startDate, Dollars(8000+40000+30000), Dollars(25))
+	investment.Reinvestment = &CashReserve{Percent: 3}
+	investment.MonthlyPortfolio = true
+
+	// Notes are sold at a discount when retirement withdrawals need more than the cash
+	investment.Market = &SecondaryMarket{TradingFee: 1, LiquidationMarkup: -2}
+
+	decumulation := &Decumulation{
+		To:         "Checking",
+		Sources:    []string{"Investment", "401k"},
+		Strategy:   &Guardrails{Rate: 4.5, Inflation: 3, Upper: 20, Lower: 20, Adjustment: 10},
+		StartDate:  retireDate,
+		DayOfMonth: 1,
+	}
+
+	// Earnings before the simulation for the Social Security benefit
+	priorEarnings := map[int]USD{}
+	for year := 2007; year < startDate.Year(); year++ {
+		priorEarnings[year] = Dollars(60000 + 4000*USD(year-2007))
+	}
 
 	// Create the bank accounts
-	bank := Bank{
+	bank := &Bank{
+		Name: "Household",
 		Accounts: map[string]Account{
-			"Checking":   NewBankAccount("Checking", startDate, Dollars(500)),
-			"Investment": NewPeer2PeerAccount("Investment", startDate, Dollars(8000+40000+30000), Dollars(25)),
-			"Mortgage":   NewLoan("Mortgage", Dollars(173600), 4.875, 30, 204),
+			"Checking":     NewBankAccount("Checking", startDate, Dollars(500)),
+			"Investment":   investment,
+			"Mortgage":     NewLoan("Mortgage", Dollars(173600), 4.875, 30, 204),
+			"401k":         retirement,
+			"Euro Savings": NewCurrencyAccount("Euro Savings", startDate, 0, CurrencyEUR),
+			"Family Loan":  NewLoan("Family Loan", Dollars(20000), 2, 5, 0),
+			"Home":         NewPropertyAccount("Home", startDate, Dollars(245000), &FixedAppreciation{Rate: 3}, "Mortgage"),
 		},
 		LineItems: []LineItem{
-			&MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(7000), Type: Deposit, DayOfMonth: 1, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransaction{Account: "Checking", Name: "Salary", Amount: Dollars(7000), Type: Deposit, DayOfMonth: 15, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransaction{Account: "Checking", Name: "BCBS", Amount: Dollars(630), Type: Withdrawal, DayOfMonth: 17, StartDate: startDate, EndDate: endDate},
+			&Raise{Account: "Checking", Income: salary, Percent: 3, Month: time.January, Day: 1, PromotionRate: 10, PromotionPercent: 8, StartDate: startDate, EndDate: retireDate},
+			&JobLoss{
+				Account:       "Checking",
+				Income:        []LineItem{salary[0], salary[1]},
+				AnnualRate:    3,
+				MinMonths:     2,
+				MaxMonths:     12,
+				Duration:      unemploymentBeta,
+				Benefits:      &MonthlyTransaction{Account: "Checking", Name: "Unemployment", Category: "Benefits", Amount: Dollars(1800), Type: Deposit, DayOfMonth: 1, StartDate: startDate, EndDate: retireDate},
+				BenefitMonths: 6,
+				EndDate:       retireDate,
+			},
+			&SocialSecurity{
+				Account:           "Checking",
+				Wages:             "Salary",
+				BirthDate:         time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC),
+				ClaimDate:         time.Date(2052, 7, 1, 0, 0, 0, 0, time.UTC),
+				FullRetirementAge: 67,
+				PriorEarnings:     priorEarnings,
+				WageGrowth:        3.5,
+				COLA:              2.5,
+				DayOfMonth:        3,
+			},
+			&Pension{Account: "Checking", Name: "Pension", Amount: Dollars(1500), DayOfMonth: 1, StartDate: retireDate, SurvivorPercent: 50},
+			&RetirementContribution{From: "Checking", To: "401k", Salary: "Salary", Percent: 10, MatchPercent: 50, MatchLimit: 6},
+			&RandomExpense{Account: "Checking", Name: "Medical", Category: "Medical", AnnualRate: 20, BaseAmount: Dollars(500), MaxAmount: Dollars(15000), Beta: medicalBeta},
+			&MonthlyTransaction{Account: "Checking", Name: "BCBS", Category: "Insurance", Amount: Dollars(630), Type: Withdrawal, DayOfMonth: 17, StartDate: startDate, EndDate: endDate},
 			// &MonthlyTransaction{Account: "Checking", Name: "Mortgage", Amount: Dollars(1154), Type: Withdrawal, DayOfMonth: 2, StartDate: startDate, EndDate: endDate},
 			&LoanPayment{From: "Checking", To: "Mortgage", DayOfMonth: 2},
+			&HouseholdLoanPayment{From: "Checking", Loan: "Family Loan", Household: "Parents", To: "Checking", DayOfMonth: 15},
+			&PropertyMaintenance{Property: "Home", From: "Checking", Rate: 1, DayOfMonth: 5},
+			// &PropertySale{Property: "Home", To: "Checking", SellingCosts: 6, Date: time.Date(2040, 6, 1, 0, 0, 0, 0, time.UTC)},
 
-			&MonthlyTransaction{Account: "Checking", Name: "Water", Amount: Dollars(60), Type: Withdrawal, DayOfMonth: 20, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransaction{Account: "Checking", Name: "Electricity", Amount: Dollars(115), Type: Withdrawal, DayOfMonth: 10, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransaction{Account: "Checking", Name: "Internet", Amount: Dollars(40), Type: Withdrawal, DayOfMonth: 12, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransaction{Account: "Checking", Name: "Phones", Amount: Dollars(100), Type: Withdrawal, DayOfMonth: 8, StartDate: startDate, EndDate: endDate},
-			&DailyRandomTransaction{Account: "Checking", Name: "Restaurant Food", BaseAmount: Dollars(25), MaxAmount: Dollars(60), Beta: foodBeta, Type: Withdrawal, StartDate: startDate, EndDate: endDate, Percentages: map[time.Weekday]float64{
+			&MonthlyTransaction{Account: "Checking", Name: "Water", Category: "Utilities", Amount: Dollars(60), Type: Withdrawal, DayOfMonth: 20, StartDate: startDate, EndDate: endDate},
+			&MonthlyTransaction{Account: "Checking", Name: "Electricity", Category: "Utilities", Amount: Dollars(115), Type: Withdrawal, DayOfMonth: 10, StartDate: startDate, EndDate: endDate},
+			&MonthlyTransaction{Account: "Checking", Name: "Internet", Category: "Utilities", Amount: Dollars(40), Type: Withdrawal, DayOfMonth: 12, StartDate: startDate, EndDate: endDate},
+			&MonthlyTransaction{Account: "Checking", Name: "Phones", Category: "Utilities", Amount: Dollars(100), Type: Withdrawal, DayOfMonth: 8, StartDate: startDate, EndDate: endDate},
+			&DailyRandomTransaction{Account: "Checking", Name: "Restaurant Food", Category: "Dining", BaseAmount: Dollars(25), MaxAmount: Dollars(60), Beta: foodBeta, Type: Withdrawal, StartDate: startDate, EndDate: endDate, Percentages: map[time.Weekday]float64{
 				time.Monday:    .25,
 				time.Tuesday:   .25,
 				time.Wednesday: .25,
@@ -53,43 +324,85 @@ func main() {
 				time.Sunday:    .75,
 			}},
 			&LoanPayment{From: "Checking", To: "Mortgage"},
-			&MonthlyTransfer{From: "Checking", To: "Investment", Amount: Dollars(2000), DayOfMonth: 2, StartDate: startDate, EndDate: endDate},
-			&MonthlyTransfer{From: "Checking", To: "Investment", Amount: Dollars(2000), DayOfMonth: 17, StartDate: startDate, EndDate: endDate},
+
+			// Investment transfers are paused while there is less than 3 months of expenses in checking
+			&ConditionalItem{
+				When: &EmergencyFundGoal{Account: "Checking", Expenses: "Checking", Months: 3},
+				Item: &MonthlyTransfer{From: "Checking", To: "Investment", Amount: Dollars(2000), DayOfMonth: 2, StartDate: startDate, EndDate: retireDate},
+			},
+			&ConditionalItem{
+				When: &EmergencyFundGoal{Account: "Checking", Expenses: "Checking", Months: 3},
+				Item: &MonthlyTransfer{From: "Checking", To: "Investment", Amount: Dollars(2000), DayOfMonth: 17, StartDate: startDate, EndDate: retireDate},
+			},
+			&MonthlyTransfer{From: "Checking", To: "Investment", AmountExpr: MustParseAmount(`0.10 * income("Salary", month)`), DayOfMonth: 16, StartDate: startDate, EndDate: retireDate},
+			&Sweep{From: "Checking", To: "Investment", Threshold: Dollars(50000), When: All{&OnDayOfMonth{Day: 1}, &Between{EndDate: retireDate}}},
+			decumulation,
+			&MonthlyTransfer{From: "Checking", To: "Euro Savings", Amount: Dollars(500), DayOfMonth: 20, StartDate: startDate, EndDate: retireDate},
+			&EarlyPayoff{From: "Checking", Loan: "Mortgage", When: &BalanceBelow{Account: "Mortgage", Amount: Dollars(30000)}},
+		},
+		Returns: NewReturnTracker(),
+		FX:      FixedRates{CurrencyEUR: 0.92, CurrencyGBP: 0.79},
+		Goals: []Goal{
+			&EmergencyFundGoal{Account: "Checking", Expenses: "Checking", Months: 6},
+			&BalanceGoal{Account: "Investment", Amount: Dollars(1000000)},
+			&PaidOffGoal{Loan: "Mortgage"},
 		},
 	}
 
-	dailyOutput, err := os.OpenFile("daily.csv", os.O_CREATE|os.O_RDWR, 0755)
-	if err != nil {
-		log.Fatal(err)
-		return
+	// The parents rent the basement apartment and lent the household money
+	parents := &Bank{
+		Name: "Parents",
+		Accounts: map[string]Account{
+			"Checking": NewBankAccount("Checking", startDate, Dollars(60000)),
+		},
+		LineItems: []LineItem{
+			&Pension{Account: "Checking", Name: "Pension", Amount: Dollars(3200), COLA: 2, DayOfMonth: 1, StartDate: startDate},
+			&MonthlyTransaction{Account: "Checking", Name: "Social Security", Category: "Social Security", Amount: Dollars(2400), Type: Deposit, DayOfMonth: 3, StartDate: startDate, EndDate: endDate},
+			&MonthlyTransaction{Account: "Checking", Name: "Living Expenses", Category: "Living", Amount: Dollars(3000), Type: Withdrawal, DayOfMonth: 10, StartDate: startDate, EndDate: endDate},
+			&HouseholdTransfer{From: "Checking", Household: "Household", To: "Checking", Name: "Rent", Category: "Rent", Amount: Dollars(900), DayOfMonth: 1, StartDate: startDate, EndDate: endDate},
+			&HouseholdTransfer{From: "Checking", Household: "Household", To: "Checking", Name: "Family Loan", Category: CategoryTransfer, Amount: Dollars(20000), DayOfMonth: 1, StartDate: startDate, EndDate: startDate},
+		},
 	}
-	dailyOutput.Truncate(0)
-	defer dailyOutput.Close()
 
-	monthlyOutput, err := os.OpenFile("monthly.csv", os.O_CREATE|os.O_RDWR, 0755)
-	if err != nil {
-		log.Fatal(err)
-		return
+	return &scenario{bank, parents, investment, decumulation}
+}
+
+// monteCarlo simulates new scenarios the given number of times and returns the monthly net worth and goal
+// results of each run. Logging is discarded during the runs.
+func monteCarlo(runs int, startDate, endDate, retireDate time.Time) ([][]SeriesPoint, []GoalResults) {
+	if runs <= 0 {
+		return nil, nil
 	}
-	monthlyOutput.Truncate(0)
-	defer monthlyOutput.Close()
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stdout)
 
-	var wg sync.WaitGroup
-	engine := NewEngine(ctx, cancel, ProcessList{
-		NewDefaultProcess(ctx, "Date Process", &DayGenerator{startDate, endDate}, ProcessList{
-			NewDefaultProcess(ctx, "Bank Process", &bank, ProcessList{
-				NewDefaultProcess(ctx, "Monthly Output", &MonthlyOutput{monthlyOutput}, ProcessList{}),
-				NewDefaultProcess(ctx, "Daily Output", &DailyOutput{dailyOutput}, ProcessList{}),
-			}),
-		}),
-	})
-	engine.Start(&wg)
+	var netWorth [][]SeriesPoint
+	var goals []GoalResults
+	for i := 0; i < runs; i++ {
+		s := newScenario(startDate, endDate, retireDate)
+		report := NewHTMLReport(ioutil.Discard, "", "Checking")
 
-	wg.Wait()
+		interbank := NewInterbank("Household", "Parents")
+		if err := interbank.Validate(s.bank, s.parents); err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = WithService(ctx, interbank)
 
-	fmt.Println()
-	fmt.Println(bank.Accounts["Checking"])
-	fmt.Println(bank.Accounts["Investment"])
-	fmt.Println(bank.Accounts["Mortgage"])
-	fmt.Println("\nExiting...")
+		var wg sync.WaitGroup
+		engine := NewEngine(ctx, cancel, ProcessList{
+			NewDefaultProcess(ctx, "Date Process", &DayGenerator{startDate, endDate}, ProcessList{
+				NewDefaultProcess(ctx, "Bank Process", s.bank, ProcessList{
+					NewDefaultProcess(ctx, "Report Output", &SinkOutput{report}, ProcessList{}),
+				}),
+				NewDefaultProcess(ctx, "Parents Process", s.parents, ProcessList{}),
+			}),
+		})
+		engine.Start(&wg)
+		wg.Wait()
+
+		netWorth = append(netWorth, report.NetWorth())
+		goals = append(goals, s.bank.GoalResults())
+	}
+	return netWorth, goals
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// SellStrategy decides which notes are listed on the secondary market and at what markup.
+// Markups are percentages of the outstanding principal and negative markups are discounts.
+type SellStrategy interface {
+	Sell(note *MicroLoan, date time.Time) (markup float64, ok bool)
+}
+
+// LateSellStrategy lists notes which are at least the given number of days late.
+type LateSellStrategy struct {
+	DaysLate int
+	Markup   float64
+}
+
+// Sell returns true if the note is late enough to be sold.
+func (s *LateSellStrategy) Sell(note *MicroLoan, date time.Time) (float64, bool) {
+	if !note.Late() || date.Before(note.LateSince.AddDate(0, 0, s.DaysLate)) {
+		return 0, false
+	}
+	return s.Markup, true
+}
+
+// SecondaryMarket allows a peer-to-peer account to sell notes and buy seasoned notes before maturity.
+type SecondaryMarket struct {
+
+	// FillRate is the percent chance a listed note is sold each day
+	FillRate float64
+
+	// TradingFee is the percent of the sale price charged to the seller
+	TradingFee float64
+
+	// SellStrategy lists notes for sale, if any
+	SellStrategy SellStrategy
+
+	// Listings is the number of seasoned notes offered each day
+	Listings int
+
+	// MarkupRange is the percent range seasoned notes are offered within
+	MarkupRange float64
+
+	// MaxMarkup is the highest markup paid for seasoned notes
+	MaxMarkup float64
+
+	// LiquidationMarkup is the markup notes are sold at to cover withdrawals, usually a discount
+	LiquidationMarkup float64
+}
+
+// Process lists notes picked by the sell strategy, fills listed notes and buys seasoned notes.
+func (s *SecondaryMarket) Process(date time.Time, acct *Peer2PeerAccount) {
+	if s.SellStrategy != nil {
+		for _, note := range acct.Active {
+			if note.Listed || note.OutstandingPrincipal <= 0 {
+				continue
+			}
+			if markup, ok := s.SellStrategy.Sell(note, date); ok {
+				acct.List(note, markup)
+			}
+		}
+	}
+
+	// Simulated buyers
+	listed := acct.ForSale[:0]
+	for _, note := range acct.ForSale {
+		if note.OutstandingPrincipal <= 0 {
+			note.Listed = false
+			continue
+		}
+		if rand.Float64() < s.FillRate/100. {
+			if err := s.sell(date, acct, note); err != nil {
+				log.Println("ERR: ", err)
+			}
+			continue
+		}
+		listed = append(listed, note)
+	}
+	acct.ForSale = listed
+
+	for i := 0; i < s.Listings && acct.investable > acct.PerInvestment; i++ {
+		note, markup := s.seasoned(date, acct)
+		if markup > s.MaxMarkup || (acct.Strategy != nil && !acct.Strategy.Accept(note.listing(), acct)) {
+			continue
+		}
+		if err := s.buy(date, acct, note, markup); err != nil {
+			log.Println("ERR: ", err)
+		}
+	}
+}
+
+// proceeds returns the sale price of the outstanding principal at the markup less the trading fee.
+func (s *SecondaryMarket) proceeds(outstanding USD, markup float64) USD {
+	price := outstanding.Mul(1+markup/100., RoundHalfEven)
+	return price - price.Percent(s.TradingFee, RoundHalfEven)
+}
+
+func (s *SecondaryMarket) sell(date time.Time, acct *Peer2PeerAccount, note *MicroLoan) error {
+	outstanding := note.OutstandingPrincipal
+	proceeds := s.proceeds(outstanding, note.Markup)
+	acct.TradingGains += proceeds - outstanding
+	acct.AccountValue += proceeds - outstanding
+	acct.AvailableCash += proceeds
+	acct.reducePrincipal(note.Grade, outstanding)
+	acct.MonthlyCashFlow += proceeds
+	acct.DailyCashFlow += proceeds
+
+	note.TotalPaid += proceeds
+	note.OutstandingPrincipal = 0
+	note.Listed = false
+	note.Sold = true
+	return acct.Append(Transaction{
+		Date:        date,
+		Type:        NoteSale,
+		Description: fmt.Sprintf("Sale of loan #%d", note.ID),
+		Amount:      proceeds,
+	})
+}
+
+// seasoned generates a note which has already made some of its payments. The note was issued a month
+// before each payment so its vintage matches its age.
+func (s *SecondaryMarket) seasoned(date time.Time, acct *Peer2PeerAccount) (*MicroLoan, float64) {
+	listing := acct.Grades.Listing()
+	payments := rand.Intn(listing.Term - 1)
+	note := newMicroLoan(len(acct.MicroLoans), listing, acct.PerInvestment, date.AddDate(0, -payments, 0))
+	for ; note.Payments < payments; note.Payments++ {
+		principal, _ := note.nextPayment()
+		note.OutstandingPrincipal -= principal
+	}
+	note.DueDate = note.StartDate.AddDate(0, payments+1, 0)
+	note.PayDay = date.AddDate(0, 0, int(payDateBeta.Random()*60))
+	return note, (rand.Float64()*2 - 1) * s.MarkupRange
+}
+
+func (s *SecondaryMarket) buy(date time.Time, acct *Peer2PeerAccount, note *MicroLoan, markup float64) error {
+	outstanding := note.OutstandingPrincipal
+	price := outstanding.Mul(1+markup/100., RoundHalfEven)
+	if price > acct.AvailableCash || price > acct.investable {
+		return nil
+	}
+
+	note.Cost = price
+	acct.addLoan(note, date)
+	acct.TradingGains += outstanding - price
+	acct.AccountValue += outstanding - price
+	acct.AvailableCash -= price
+	acct.investable -= price
+	acct.OutstandingPrincipal += outstanding
+	acct.GradePrincipal[note.Grade] += outstanding
+	acct.Invested += price
+	return acct.Append(Transaction{
+		Date:        date,
+		Type:        NotePurchase,
+		Description: fmt.Sprintf("Purchase of loan #%d", note.ID),
+		Amount:      price,
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// investNotes buys the given number of grade A notes with the account's cash.
+func investNotes(acct *Peer2PeerAccount, date time.Time, count int) {
+	for i := 0; i < count; i++ {
+		note := newMicroLoan(len(acct.MicroLoans), NoteListing{Grade: "A", Rate: 7, Term: 36}, acct.PerInvestment, date)
+		acct.addLoan(note, date)
+		acct.OutstandingPrincipal += acct.PerInvestment
+		acct.GradePrincipal[note.Grade] += acct.PerInvestment
+		acct.AvailableCash -= acct.PerInvestment
+		acct.Invested += acct.PerInvestment
+	}
+}
+
+func TestLiquidateWithdrawal(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	acct.Market = &SecondaryMarket{TradingFee: 1, LiquidationMarkup: -2}
+	investNotes(acct, date, 4)
+
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking":   NewBankAccount("Checking", date, 0),
+		"Investment": acct,
+	}}
+	if acct.AvailableCash != 0 {
+		t.Fatalf("available cash = %s", acct.AvailableCash)
+	}
+	if err := bank.Transfer(date, "Investment", "Checking", Dollars(60)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each $25 note sells for $24.50 less a $0.24 fee, so three notes cover the withdrawal
+	var sold int
+	for _, note := range acct.MicroLoans {
+		if note.Sold {
+			sold++
+		}
+	}
+	if sold != 3 {
+		t.Errorf("sold %d notes, want 3", sold)
+	}
+	if got, want := acct.AvailableCash, 3*USD(2426)-Dollars(60); got != want {
+		t.Errorf("available cash = %s, want %s", got, want)
+	}
+	if got, want := acct.OutstandingPrincipal, Dollars(25); got != want {
+		t.Errorf("outstanding = %s, want %s", got, want)
+	}
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(60) {
+		t.Errorf("checking = %s", got)
+	}
+
+	// Withdrawals beyond the liquidation value fail without selling anything
+	if err := bank.Transfer(date, "Investment", "Checking", Dollars(100)); err != ErrInvalidTransfer {
+		t.Errorf("err = %v, want %v", err, ErrInvalidTransfer)
+	}
+	if acct.MicroLoans[3].Sold {
+		t.Errorf("the last note should not be sold")
+	}
+}
+
+func TestLiquidateWithoutMarket(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	investNotes(acct, date, 2)
+
+	if got := acct.LiquidationValue(); got != Dollars(50) {
+		t.Errorf("liquidation value = %s, want the cash", got)
+	}
+	if err := acct.Append(Transaction{Date: date, Type: Withdrawal, Amount: Dollars(60)}); err != ErrInsufficientFunds {
+		t.Errorf("err = %v, want %v", err, ErrInsufficientFunds)
+	}
+}
+
+func TestSecondaryMarketSellsLateNotes(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(50), Dollars(25))
+	market := &SecondaryMarket{FillRate: 100, SellStrategy: &LateSellStrategy{DaysLate: 30, Markup: -10}}
+	acct.Market = market
+	investNotes(acct, date, 2)
+
+	late := acct.MicroLoans[0]
+	late.LateSince = date
+
+	market.Process(date.AddDate(0, 0, 29), acct)
+	if late.Sold || late.Listed {
+		t.Fatalf("note sold before it was 30 days late")
+	}
+
+	market.Process(date.AddDate(0, 0, 30), acct)
+	if !late.Sold || acct.MicroLoans[1].Sold {
+		t.Fatalf("only the late note should be sold")
+	}
+	if got, want := acct.TradingGains, -Dollars(25)/10; got != want {
+		t.Errorf("trading gains = %s, want %s", got, want)
+	}
+	if len(acct.ForSale) != 0 {
+		t.Errorf("sold notes should be removed from the listings")
+	}
+}
+
+func TestSeasonedNoteVintage(t *testing.T) {
+	date := time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	market := &SecondaryMarket{MarkupRange: 5}
+
+	// Seasoned notes were issued a month before each payment they have made
+	for i := 0; i < 100; i++ {
+		note, _ := market.seasoned(date, acct)
+		if issued := note.StartDate.AddDate(0, note.Payments, 0); !issued.Equal(date) {
+			t.Fatalf("note with %d payments issued %s, want %s", note.Payments, note.StartDate.Format("2006-01-02"), date.AddDate(0, -note.Payments, 0).Format("2006-01-02"))
+		}
+		if !note.DueDate.After(date) || note.PayDay.Before(date) {
+			t.Fatalf("note due %s, pays %s before purchase on %s", note.DueDate, note.PayDay, date)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"log"
 	"os"
 	"time"
 )
@@ -10,56 +10,122 @@ import (
 const TypeDailyAccountInfo = MessageType("DailyAccountInfo")
 const TypeMonthlyAccountInfo = MessageType("MonthlyAccountInfo")
 
+// TypeAccounts is the message type for the list of accounts sent when the bank starts
+const TypeAccounts = MessageType("Accounts")
+
+// TypeDailyTransactions is the message type for the transactions posted during a day
+const TypeDailyTransactions = MessageType("DailyTransactions")
+
+// TypeDailyBalances is the message type for the end of day balances of every account
+const TypeDailyBalances = MessageType("DailyBalances")
+
+// TypeEvents is the message type for the events which occurred during a day
+const TypeEvents = MessageType("Events")
+
+// Snapshot periods
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+	PeriodBalance = "balance"
+)
+
 type AccountInfo struct {
 	Date          time.Time
+	Account       string
+	Period        string
 	AvailableCash USD
 	AccountValue  USD
 	CashFlow      USD
 	Interest      USD
 }
 
-// DailyOutput writes a log entry for each day during the simulation
-type DailyOutput struct {
-	File *os.File
+// AccountDescription describes an account of the bank
+type AccountDescription struct {
+	Name string
+	Kind string
+
+	// Loan is the loan account financing a property, if any
+	Loan string
+}
+
+// AccountTransaction is a transaction posted to an account
+type AccountTransaction struct {
+	Account string
+	Transaction
 }
 
-// Handle sends date messages to all the child processes.
-func (d *DailyOutput) Handle(ctx context.Context, proc Process, msg Message) {
+// Event is a notable occurrence during the simulation
+type Event struct {
+	Date        time.Time
+	Account     string
+	Type        string
+	Description string
+}
+
+// Sink receives the typed output of the simulation.
+type Sink interface {
+	Account(acct AccountDescription) error
+	Snapshot(info AccountInfo) error
+	Transaction(tx AccountTransaction) error
+	Event(e Event) error
+	Close() error
+}
+
+// SinkOutput writes the account snapshots, transactions and events to a sink. The sink must be
+// closed once the simulation has finished.
+type SinkOutput struct {
+	Sink Sink
+}
+
+// Handle passes the simulation output to the sink.
+func (d *SinkOutput) Handle(ctx context.Context, proc Process, msg Message) {
+	var err error
 	switch msg.Type {
-	case MessageTypeStart:
-		d.File.WriteString("date,available,value,cashflow,interest\n")
-	case TypeDailyAccountInfo:
-		info := msg.Value.(AccountInfo)
-		d.File.WriteString(fmt.Sprintf("%s,%.2f,%.2f,%.2f,%.2f\n",
-			info.Date.Format("2006-01-02"),
-			float64(info.AvailableCash)/100,
-			float64(info.AccountValue)/100,
-			float64(info.CashFlow)/100,
-			float64(info.Interest)/100,
-		))
-	case MessageTypeStop:
+	case TypeAccounts:
+		for _, acct := range msg.Value.([]AccountDescription) {
+			if err = d.Sink.Account(acct); err != nil {
+				break
+			}
+		}
+	case TypeDailyAccountInfo, TypeMonthlyAccountInfo:
+		err = d.Sink.Snapshot(msg.Value.(AccountInfo))
+	case TypeDailyBalances:
+		for _, info := range msg.Value.([]AccountInfo) {
+			if err = d.Sink.Snapshot(info); err != nil {
+				break
+			}
+		}
+	case TypeDailyTransactions:
+		for _, tx := range msg.Value.([]AccountTransaction) {
+			if err = d.Sink.Transaction(tx); err != nil {
+				break
+			}
+		}
+	case TypeEvents:
+		for _, e := range msg.Value.([]Event) {
+			if err = d.Sink.Event(e); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Println("ERR: ", err)
 	}
 }
 
-// MonthlyOutput writes a log entry on the first of each month during the simulation
-type MonthlyOutput struct {
+// PortfolioOutput writes the monthly portfolio reports of peer-to-peer accounts during the simulation
+type PortfolioOutput struct {
 	File *os.File
 }
 
-// Handle sends date messages to all the child processes.
-func (d *MonthlyOutput) Handle(ctx context.Context, proc Process, msg Message) {
+// Handle writes portfolio reports to the output file.
+func (d *PortfolioOutput) Handle(ctx context.Context, proc Process, msg Message) {
 	switch msg.Type {
 	case MessageTypeStart:
-		d.File.WriteString("date,available,value,cashflow,interest\n")
-	case TypeMonthlyAccountInfo:
-		info := msg.Value.(AccountInfo)
-		d.File.WriteString(fmt.Sprintf("%s,%.2f,%.2f,%.2f,%.2f\n",
-			info.Date.Format("2006-01-02"),
-			float64(info.AvailableCash)/100,
-			float64(info.AccountValue)/100,
-			float64(info.CashFlow)/100,
-			float64(info.Interest)/100,
-		))
+		WritePortfolioHeader(d.File)
+	case TypeMonthlyPortfolioInfo:
+		report := msg.Value.(PortfolioReport)
+		report.WriteCSV(d.File)
 	case MessageTypeStop:
 	}
 }
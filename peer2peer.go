@@ -27,104 +27,171 @@ func NewPeer2PeerAccount(name string, date time.Time, init, perInvestment USD) *
 		Invested:             0,
 		Interest:             0,
 		OutstandingPrincipal: 0,
+		GradePrincipal:       map[string]USD{},
+		MaxDailyNotes:        85,
+		ScalePerInvestment:   true,
+		Grades:               DefaultLoanGrades,
+		CreditModel:          DefaultLoanGrades.CreditModel(),
 		Ledger: []Transaction{
-			Transaction{Date: date, Description: "Initial deposit", Type: Deposit, Amount: init},
+			Transaction{Date: date, Description: "Initial deposit", Type: Deposit, Amount: init, Balance: init},
 		},
 	}
 }
 
+// MicroLoan is a single note held by a peer-to-peer lending account.
 type MicroLoan struct {
 	ID                   int
+	Grade                string
+	Term                 int
 	StartDate            time.Time
 	DueDate              time.Time
 	PayDay               time.Time
+	LateSince            time.Time
+	ChargeOffDate        time.Time
+	Defaulted            bool
+	Listed               bool
+	Sold                 bool
+	Markup               float64
+	Payments             int
 	InterestRate         float64
-	MonthlyPrincipal     USD
-	MonthlyInterest      USD
+	MonthlyPayment       USD
+	Amount               USD
 	OutstandingPrincipal USD
 	TotalPaid            USD
+	Loss                 USD
+
+	// Cost is the amount paid for the note, which is the price of notes bought on the secondary market
+	Cost USD
 }
 
-func (m *MicroLoan) chargeOff(date time.Time, acct *Peer2PeerAccount) error {
-	err := acct.Append(Transaction{
-		Date:        date,
-		Type:        MonthlyPayment,
-		Description: fmt.Sprintf("Charge-off for loan #%d", m.ID),
-		Amount:      m.OutstandingPrincipal,
-	})
-	if err != nil {
-		return err
+// newMicroLoan creates a level payment loan for the note listing.
+func newMicroLoan(id int, note NoteListing, amount USD, start time.Time) *MicroLoan {
+	r := note.Rate / 100. / 12.
+	payment := amount.Split(note.Term)[0]
+	if r > 0 {
+		payment = amount.Mul(r/(1-math.Pow(1+r, -float64(note.Term))), RoundHalfEven)
+	}
+
+	return &MicroLoan{
+		ID:                   id,
+		Grade:                note.Grade,
+		Term:                 note.Term,
+		StartDate:            start,
+		DueDate:              start.AddDate(0, 1, 0),
+		PayDay:               start.AddDate(0, 0, int(payDateBeta.Random()*60)),
+		InterestRate:         note.Rate,
+		MonthlyPayment:       payment,
+		Amount:               amount,
+		OutstandingPrincipal: amount,
+		TotalPaid:            0,
+		Cost:                 amount,
 	}
-	acct.Interest += m.MonthlyInterest
-	acct.AccountValue += m.MonthlyInterest
-	acct.AvailableCash += m.MonthlyInterest + m.OutstandingPrincipal
-	acct.OutstandingPrincipal -= m.OutstandingPrincipal
-	acct.MonthlyCashFlow += m.MonthlyInterest + m.MonthlyPrincipal
-	acct.MonthlyInterest += m.MonthlyInterest
-	acct.DailyCashFlow += m.MonthlyInterest + m.MonthlyPrincipal
-	acct.DailyInterest += m.MonthlyInterest
-
-	m.TotalPaid += m.OutstandingPrincipal + m.MonthlyInterest
+}
+
+// listing returns the note listing for the loan.
+func (m *MicroLoan) listing() NoteListing {
+	return NoteListing{Grade: m.Grade, Rate: m.InterestRate, Term: m.Term}
+}
+
+// nextPayment returns the principal and interest of the next payment. Interest accrues on the
+// remaining principal and the final payment pays off whatever principal is left from rounding.
+func (m *MicroLoan) nextPayment() (principal, interest USD) {
+	interest = m.OutstandingPrincipal.Percent(m.InterestRate/12., RoundHalfEven)
+	principal = m.MonthlyPayment - interest
+	if m.Payments >= m.Term-1 || principal > m.OutstandingPrincipal {
+		principal = m.OutstandingPrincipal
+	}
+	return principal, interest
+}
+
+// Late returns true if the loan has missed its last payment.
+func (m *MicroLoan) Late() bool {
+	return !m.LateSince.IsZero()
+}
+
+func (m *MicroLoan) chargeOff(date time.Time, acct *Peer2PeerAccount, risk *CreditRisk) error {
+	recovered := risk.Recovery(m.OutstandingPrincipal)
+	loss := m.OutstandingPrincipal - recovered
+	acct.ChargeOffs += loss
+	acct.Recoveries += recovered
+	acct.AccountValue -= loss
+	acct.AvailableCash += recovered
+	acct.reducePrincipal(m.Grade, m.OutstandingPrincipal)
+	acct.MonthlyCashFlow += recovered
+	acct.DailyCashFlow += recovered
+
+	m.TotalPaid += recovered
+	m.Loss = loss
+	m.ChargeOffDate = date
 	m.OutstandingPrincipal = 0
-	return nil
+	return acct.Append(Transaction{
+		Date:        date,
+		Type:        ChargeOff,
+		Description: fmt.Sprintf("Charge-off for loan #%d, %s lost", m.ID, loss),
+		Amount:      recovered,
+	})
 }
 
+// Process collects the payment due on the given date, applying the credit risk of the loan grade.
 func (m *MicroLoan) Process(date time.Time, acct *Peer2PeerAccount) error {
+	if m.OutstandingPrincipal <= 0 || (!m.Defaulted && m.PayDay.After(date)) {
+		return nil
+	}
+	risk := acct.CreditModel.Risk(m.Grade)
 
-	if m.OutstandingPrincipal > 0 {
+	// Defaulted loans are charged off after the grace period
+	if m.Defaulted {
+		if !date.Before(m.LateSince.AddDate(0, 0, risk.GracePeriod)) {
+			return m.chargeOff(date, acct, risk)
+		}
+		return nil
+	}
 
-		// Paid on time
-		if equalDates(m.PayDay, date) {
+	if !m.Late() {
 
-			// 2% charge-off chance
-			if rand.Float64() < 0.005 {
-				return m.chargeOff(date, acct)
-			}
+		// Missed payment which will never be made
+		if rand.Float64() < risk.DefaultProbability(m.Payments, m.Term) {
+			m.Defaulted = true
+			m.LateSince = date
+			return nil
+		}
 
-			// // 75% late payment chance
-			// if rand.Float64() < 0.75 {
-			// 	beta, _ = prob.NewBeta(2, 4)
-			// 	m.PayDay.AddDate(0, 0, int(beta.Random()*14))
-			// 	return nil
-			// } else {
-			// 	beta, _ = prob.NewBeta(5, 10)
-			// 	m.PayDay.AddDate(0, 1, int(beta.Random()*30))
-			// 	return nil
-			// }
-
-			// // failed payment
-			// if rand.Float64() > 0.98 {
-			// 	m.TotalPaid += m.OutstandingPrincipal
-			// 	m.OutstandingPrincipal = 0
-			// 	return nil
-			// }
-
-			err := acct.Append(Transaction{
-				Date:        date,
-				Type:        MonthlyPayment,
-				Description: fmt.Sprintf("Payment on loan #%d", m.ID),
-				Amount:      m.MonthlyInterest + m.MonthlyPrincipal,
-			})
-			if err != nil {
-				return err
-			}
-			m.TotalPaid += m.MonthlyInterest + m.MonthlyPrincipal
-			m.OutstandingPrincipal -= m.MonthlyPrincipal
-			acct.Interest += m.MonthlyInterest
-			acct.AccountValue += m.MonthlyInterest
-			acct.AvailableCash += m.MonthlyInterest + m.MonthlyPrincipal
-			acct.OutstandingPrincipal -= m.MonthlyPrincipal
-			acct.MonthlyCashFlow += m.MonthlyInterest + m.MonthlyPrincipal
-			acct.MonthlyInterest += m.MonthlyInterest
-			acct.DailyCashFlow += m.MonthlyInterest + m.MonthlyPrincipal
-			acct.DailyInterest += m.MonthlyInterest
-
-			// Increment due date for next payment
-			m.DueDate = date.AddDate(0, 1, 0)
-			m.PayDay = date.AddDate(0, 0, int(payDateBeta.Random()*60))
+		// Late payment made within the grace period
+		if rand.Float64() < risk.LatePaymentRate/100. {
+			m.LateSince = date
+			m.PayDay = date.AddDate(0, 0, 1+int(payDateBeta.Random()*float64(risk.GracePeriod/2)))
+			return nil
 		}
 	}
-	return nil
+
+	principal, interest := m.nextPayment()
+	if m.Late() {
+		interest += risk.LateFee
+		acct.LateFees += risk.LateFee
+		m.LateSince = time.Time{}
+	}
+
+	m.Payments++
+	m.TotalPaid += interest + principal
+	m.OutstandingPrincipal -= principal
+	acct.Interest += interest
+	acct.AccountValue += interest
+	acct.AvailableCash += interest + principal
+	acct.reducePrincipal(m.Grade, principal)
+	acct.MonthlyCashFlow += interest + principal
+	acct.MonthlyInterest += interest
+	acct.DailyCashFlow += interest + principal
+	acct.DailyInterest += interest
+
+	// Increment due date for next payment
+	m.DueDate = date.AddDate(0, 1, 0)
+	m.PayDay = date.AddDate(0, 0, int(payDateBeta.Random()*60))
+	return acct.Append(Transaction{
+		Date:        date,
+		Type:        MonthlyPayment,
+		Description: fmt.Sprintf("Payment on loan #%d", m.ID),
+		Amount:      interest + principal,
+	})
 }
 
 // Peer2PeerAccount represents a bank account.
@@ -142,8 +209,38 @@ type Peer2PeerAccount struct {
 	MonthlyInterest      USD
 	DailyCashFlow        USD
 	DailyInterest        USD
-	Ledger               []Transaction
-	MicroLoans           []*MicroLoan
+	LateFees             USD
+	ChargeOffs           USD
+	Recoveries           USD
+	TradingGains         USD
+	GradePrincipal       map[string]USD
+	Grades               LoanGrades
+	CreditModel          CreditModel
+	Strategy             InvestmentStrategy
+	Market               *SecondaryMarket
+	ForSale              []*MicroLoan
+	Reinvestment         ReinvestmentPolicy
+
+	// MaxDailyNotes is the number of new notes offered each day
+	MaxDailyNotes int
+
+	// ScalePerInvestment doubles PerInvestment whenever a day's returns exceed four notes,
+	// which keeps the number of notes manageable as the portfolio grows.
+	ScalePerInvestment bool
+
+	// MonthlyPortfolio broadcasts a portfolio report on the first of each month
+	MonthlyPortfolio bool
+
+	Ledger     []Transaction
+	MicroLoans []*MicroLoan
+
+	// Active holds the notes with outstanding principal. Paid off, charged-off and sold
+	// notes are removed on the first of each month.
+	Active []*MicroLoan
+
+	// schedule indexes the active notes by the next date they need to be processed
+	schedule   map[int][]*MicroLoan
+	investable USD
 }
 
 // CurrentBalance returns the current balance of the account
@@ -151,6 +248,16 @@ func (a *Peer2PeerAccount) CurrentBalance() USD {
 	return a.AvailableCash
 }
 
+// MarketValue returns the value of the cash and outstanding notes
+func (a *Peer2PeerAccount) MarketValue() USD {
+	return a.AccountValue
+}
+
+// Transactions returns the account ledger
+func (a *Peer2PeerAccount) Transactions() []Transaction {
+	return a.Ledger
+}
+
 // randBetaDate increments the given date by sum number of days that cooresponds to the beta distrbution
 func randBetaDate(beta prob.Beta, date time.Time, max int) time.Time {
 	newDate := date.AddDate(0, 0, 1).AddDate(0, 0, int(beta.Random()*float64(max)))
@@ -179,6 +286,8 @@ func (a *Peer2PeerAccount) broadcastMonthly(proc Process, date time.Time) {
 			Type:      TypeMonthlyAccountInfo,
 			Value: AccountInfo{
 				Date:          date,
+				Account:       a.Name,
+				Period:        PeriodMonthly,
 				AccountValue:  a.AccountValue,
 				AvailableCash: a.AvailableCash,
 				CashFlow:      a.MonthlyCashFlow,
@@ -186,6 +295,14 @@ func (a *Peer2PeerAccount) broadcastMonthly(proc Process, date time.Time) {
 			},
 			Forward: false,
 		})
+		if a.MonthlyPortfolio {
+			proc.Children().Dispatch(Message{
+				Timestamp: time.Now().UTC(),
+				Type:      TypeMonthlyPortfolioInfo,
+				Value:     NewPortfolioReport(date, a),
+				Forward:   false,
+			})
+		}
 		// log.Printf("%s Monthly %s %s %s %d\n", date.Format("2006-01-02"), a.AccountValue, a.MonthlyCashFlow, a.MonthlyInterest, len(a.MicroLoans))
 		a.MonthlyCashFlow = 0
 		a.MonthlyInterest = 0
@@ -198,6 +315,8 @@ func (a *Peer2PeerAccount) broadcastDaily(proc Process, date time.Time) {
 		Type:      TypeDailyAccountInfo,
 		Value: AccountInfo{
 			Date:          date,
+			Account:       a.Name,
+			Period:        PeriodDaily,
 			AccountValue:  a.AccountValue,
 			AvailableCash: a.AvailableCash,
 			CashFlow:      a.DailyCashFlow,
@@ -214,76 +333,208 @@ func (a *Peer2PeerAccount) Update(ctx context.Context, proc Process, bank *Bank,
 	a.broadcastMonthly(proc, date)
 	a.broadcastDaily(proc, date)
 
-	months, periods := 36, 3.
-	dailyInvestments := 85
-	for a.AvailableCash > a.PerInvestment && dailyInvestments > 0 {
-		rate := 1.08 + (rateBeta.Random() * 12 / 100)
-		totalRate := math.Pow(rate, periods)
-		principalPayment := float64(a.PerInvestment/100) / float64(months)
-		interestPayment := (float64(a.PerInvestment/100)*totalRate - float64(a.PerInvestment/100)) / float64(months)
+	a.investable = a.AvailableCash
+	if a.Reinvestment != nil {
+		a.investable = a.Reinvestment.Investable(date, bank, a)
+	}
+
+	dailyInvestments := a.MaxDailyNotes
+	for a.investable >= a.PerInvestment && a.AvailableCash > a.PerInvestment && dailyInvestments > 0 {
+		dailyInvestments--
+		note := a.Grades.Listing()
+		if a.Strategy != nil && !a.Strategy.Accept(note, a) {
+			continue
+		}
 
 		start := randBetaDate(startDateBeta, date.In(date.Location()), 7)
-		a.MicroLoans = append(a.MicroLoans, &MicroLoan{
-			ID:                   len(a.MicroLoans),
-			StartDate:            start,
-			DueDate:              start.AddDate(0, 1, 0),
-			PayDay:               start.AddDate(0, 0, int(payDateBeta.Random()*60)),
-			InterestRate:         totalRate,
-			MonthlyPrincipal:     USD(principalPayment * 100),
-			MonthlyInterest:      USD(interestPayment * 100),
-			OutstandingPrincipal: a.PerInvestment,
-			TotalPaid:            0,
-		})
+		loan := newMicroLoan(len(a.MicroLoans), note, a.PerInvestment, start)
+		a.addLoan(loan, date)
 		a.OutstandingPrincipal += a.PerInvestment
+		a.GradePrincipal[note.Grade] += a.PerInvestment
 		a.AvailableCash -= a.PerInvestment
+		a.investable -= a.PerInvestment
 		a.Invested += a.PerInvestment
-
-		dailyInvestments--
+		if err := a.Append(Transaction{
+			Date:        date,
+			Type:        NotePurchase,
+			Description: fmt.Sprintf("Investment in loan #%d", loan.ID),
+			Amount:      a.PerInvestment,
+		}); err != nil {
+			log.Println("ERR: ", err)
+		}
 	}
 
-	// Process micro-loans
+	// Process micro-loans due today
 	startingValue := a.AccountValue
-	for _, loan := range a.MicroLoans {
+	key := dayKey(date)
+	for _, loan := range a.schedule[key] {
 		//  - if due and incomplete, create txn and increment account totals with principal and interest
 		if err := loan.Process(date, a); err != nil {
 			log.Println("err: ", err)
 		}
+		a.reschedule(loan, date)
+	}
+	delete(a.schedule, key)
+
+	if date.Day() == 1 {
+		a.retire()
+	}
+	if a.Market != nil {
+		a.Market.Process(date, a)
 	}
-	if a.AccountValue-startingValue > a.PerInvestment*4 {
+	if a.ScalePerInvestment && a.AccountValue-startingValue > a.PerInvestment*4 {
 		a.PerInvestment *= 2
 	}
 }
 
+// dayKey returns the schedule key for a date
+func dayKey(date time.Time) int {
+	return date.Year()*10000 + int(date.Month())*100 + date.Day()
+}
+
+// addLoan adds a new note to the portfolio and schedules its first payment.
+func (a *Peer2PeerAccount) addLoan(loan *MicroLoan, date time.Time) {
+	a.MicroLoans = append(a.MicroLoans, loan)
+	a.Active = append(a.Active, loan)
+	a.reschedule(loan, date)
+}
+
+// reschedule schedules an active note for its next payment, or charge-off if it has defaulted.
+// Notes are never scheduled before the day after the given date.
+func (a *Peer2PeerAccount) reschedule(loan *MicroLoan, date time.Time) {
+	if loan.OutstandingPrincipal <= 0 {
+		return
+	}
+
+	next := loan.PayDay
+	if loan.Defaulted {
+		next = loan.LateSince.AddDate(0, 0, a.CreditModel.Risk(loan.Grade).GracePeriod)
+	}
+	if !next.After(date) {
+		next = date.AddDate(0, 0, 1)
+	}
+
+	if a.schedule == nil {
+		a.schedule = map[int][]*MicroLoan{}
+	}
+	key := dayKey(next)
+	a.schedule[key] = append(a.schedule[key], loan)
+}
+
+// retire removes notes without outstanding principal from the active notes.
+func (a *Peer2PeerAccount) retire() {
+	active := a.Active[:0]
+	for _, loan := range a.Active {
+		if loan.OutstandingPrincipal > 0 {
+			active = append(active, loan)
+		}
+	}
+	for i := len(active); i < len(a.Active); i++ {
+		a.Active[i] = nil
+	}
+	a.Active = active
+}
+
+// List lists a note for sale on the secondary market at the given markup.
+func (a *Peer2PeerAccount) List(note *MicroLoan, markup float64) {
+	if note.Listed || note.OutstandingPrincipal <= 0 {
+		return
+	}
+	note.Listed = true
+	note.Markup = markup
+	a.ForSale = append(a.ForSale, note)
+}
+
+// Liquidator is implemented by investment accounts which sell investments to cover withdrawals larger
+// than their cash.
+type Liquidator interface {
+	LiquidationValue() USD
+	Liquidate(date time.Time, amount USD) error
+}
+
+// sellable returns true if a note can be sold to cover a withdrawal. Late notes are not sold.
+func (a *Peer2PeerAccount) sellable(note *MicroLoan) bool {
+	return !note.Late() && note.OutstandingPrincipal > 0
+}
+
+// LiquidationValue returns the available cash plus the proceeds of selling every current note at the
+// liquidation markup of the secondary market.
+func (a *Peer2PeerAccount) LiquidationValue() USD {
+	value := a.AvailableCash
+	if a.Market == nil {
+		return value
+	}
+	for _, note := range a.Active {
+		if a.sellable(note) {
+			value += a.Market.proceeds(note.OutstandingPrincipal, a.Market.LiquidationMarkup)
+		}
+	}
+	return value
+}
+
+// Liquidate sells current notes on the secondary market at the liquidation markup until the available
+// cash covers the amount. Notes cannot be sold without a secondary market.
+func (a *Peer2PeerAccount) Liquidate(date time.Time, amount USD) error {
+	if a.Market != nil {
+		for _, note := range a.Active {
+			if a.AvailableCash >= amount {
+				break
+			}
+			if !a.sellable(note) {
+				continue
+			}
+			note.Markup = a.Market.LiquidationMarkup
+			if err := a.Market.sell(date, a, note); err != nil {
+				return err
+			}
+		}
+	}
+
+	if a.AvailableCash < amount {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// reducePrincipal reduces the outstanding principal of the account and the loan grade.
+func (a *Peer2PeerAccount) reducePrincipal(grade string, amount USD) {
+	a.OutstandingPrincipal -= amount
+	a.GradePrincipal[grade] -= amount
+}
+
 // Append appends a transaction to the account
 func (a *Peer2PeerAccount) Append(tx Transaction) error {
 	// log.Println(date.Format("2006/01/02"), item.Description())
 	if tx.Type == Deposit {
 		log.Println(a.Name, tx)
-		a.Ledger = append(a.Ledger, tx)
 		a.AccountValue += tx.Amount
 		a.AvailableCash += tx.Amount
 		a.Deposits += tx.Amount
 	} else if tx.Type == Withdrawal {
+
+		// Notes are sold to cover withdrawals larger than the available cash
 		if tx.Amount > a.AvailableCash {
-			return ErrInsufficientFunds
+			if err := a.Liquidate(tx.Date, tx.Amount); err != nil {
+				return err
+			}
 		}
-		a.Ledger = append(a.Ledger, tx)
 		a.AvailableCash -= tx.Amount
 		a.AccountValue -= tx.Amount
 		a.Withdrawals += tx.Amount
-	} else if tx.Type == MonthlyPayment {
-		a.Ledger = append(a.Ledger, tx)
-	} else {
+	} else if tx.Type != MonthlyPayment && tx.Type != ChargeOff && tx.Type != NoteSale && tx.Type != NotePurchase {
+		// Note payments and trades are recorded after the account totals are updated
 		return ErrUnknownTransactionType
 	}
+	tx.Balance = a.AvailableCash
+	a.Ledger = append(a.Ledger, tx)
 	return nil
 }
 
-// Validate validates a transaction
+// Validate validates a transaction. Withdrawals may be covered by selling notes.
 func (a *Peer2PeerAccount) Validate(tx Transaction) bool {
 	if tx.Type == Deposit {
 		return true
-	} else if tx.Type == Withdrawal && a.AvailableCash > tx.Amount {
+	} else if tx.Type == Withdrawal && (a.AvailableCash > tx.Amount || a.LiquidationValue() > tx.Amount) {
 		return true
 	}
 	return false
@@ -291,7 +542,7 @@ func (a *Peer2PeerAccount) Validate(tx Transaction) bool {
 
 // String returns the string representation of the account
 func (a *Peer2PeerAccount) String() string {
-	return fmt.Sprintf("%s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%d\n\t- %s\t%s\n\t- %s\t%s\n",
+	return fmt.Sprintf("%s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%d\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n",
 		a.Name, a.AvailableCash,
 		"Account Value:", a.AccountValue,
 		"Deposits:\t", a.Deposits,
@@ -299,5 +550,8 @@ func (a *Peer2PeerAccount) String() string {
 		"Loans:\t", len(a.MicroLoans),
 		"Per Loan:\t", a.PerInvestment,
 		"Outstanding:\t", a.OutstandingPrincipal,
+		"Charge-offs:\t", a.ChargeOffs,
+		"Recoveries:\t", a.Recoveries,
+		"Trading Gains:", a.TradingGains,
 	)
 }
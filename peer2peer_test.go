@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// benchmarkPeer2Peer simulates a peer-to-peer account receiving monthly deposits for the given number of years.
+func benchmarkPeer2Peer(b *testing.B, years int) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for i := 0; i < b.N; i++ {
+		ctx := context.Background()
+		startDate := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		endDate := startDate.AddDate(years, 0, 0)
+
+		acct := NewPeer2PeerAccount("Investment", startDate, Dollars(78000), Dollars(25))
+		bank := &Bank{
+			Accounts: map[string]Account{"Investment": acct},
+			LineItems: []LineItem{
+				&MonthlyTransaction{Account: "Investment", Name: "Deposit", Amount: Dollars(4000), Type: Deposit, DayOfMonth: 1, StartDate: startDate, EndDate: endDate},
+			},
+		}
+		proc := NewDefaultProcess(ctx, "Bank Process", bank, ProcessList{})
+
+		for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+			bank.Handle(ctx, proc, Message{Type: TypeDate, Value: date})
+		}
+	}
+}
+
+// Scanning every note ever bought each day took 0.21s, 8.45s and 32.3s for 1, 10 and 30 years with
+// -benchtime 1x. Indexing notes by pay day brought that to 0.14s, 5.20s and 15.6s on the same machine.
+// The index only removes the scan of paid off, charged-off and sold notes. Most of the remaining time
+// is the payments themselves and appending them to the ledger, which grow with the active notes.
+func BenchmarkPeer2Peer1Year(b *testing.B)   { benchmarkPeer2Peer(b, 1) }
+func BenchmarkPeer2Peer10Years(b *testing.B) { benchmarkPeer2Peer(b, 10) }
+func BenchmarkPeer2Peer30Years(b *testing.B) { benchmarkPeer2Peer(b, 30) }
+
+func TestPeer2PeerSchedule(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	startDate := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", startDate, Dollars(250), Dollars(25))
+	acct.ScalePerInvestment = false
+	acct.CreditModel = CreditModel{"A": &CreditRisk{}}
+	investNotes(acct, startDate, 10)
+	bank := &Bank{Accounts: map[string]Account{"Investment": acct}}
+	proc := NewDefaultProcess(ctx, "Bank Process", bank, ProcessList{})
+
+	// Notes are only processed on their pay day, so every note is indexed exactly once
+	for date := startDate; date.Before(startDate.AddDate(0, 3, 0)); date = date.AddDate(0, 0, 1) {
+		acct.Update(ctx, proc, bank, date)
+
+		scheduled := map[*MicroLoan]int{}
+		for key, loans := range acct.schedule {
+			if key <= dayKey(date) {
+				t.Fatalf("%s: notes scheduled on or before today", date.Format("2006-01-02"))
+			}
+			for _, loan := range loans {
+				scheduled[loan]++
+			}
+		}
+		for _, loan := range acct.Active {
+			if loan.OutstandingPrincipal > 0 && scheduled[loan] != 1 {
+				t.Fatalf("%s: note #%d scheduled %d times", date.Format("2006-01-02"), loan.ID, scheduled[loan])
+			}
+		}
+	}
+
+	// Without defaults or late payments every note has been paid within the first 60 days
+	for _, loan := range acct.MicroLoans[:10] {
+		if loan.Payments == 0 {
+			t.Errorf("note #%d made no payments", loan.ID)
+		}
+	}
+
+	// Closed notes are retired from the active notes on the first of the month
+	acct.MicroLoans[0].OutstandingPrincipal = 0
+	acct.Update(ctx, proc, bank, time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC))
+	for _, loan := range acct.Active {
+		if loan == acct.MicroLoans[0] {
+			t.Errorf("closed note was not retired")
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// TypeMonthlyPortfolioInfo is the message type for monthly portfolio reports
+const TypeMonthlyPortfolioInfo = MessageType("MonthlyPortfolioInfo")
+
+// LoanStatus is the status of a micro-loan
+type LoanStatus string
+
+// Loan statuses
+const (
+	StatusCurrent    LoanStatus = "CURRENT"
+	StatusLate       LoanStatus = "LATE"
+	StatusChargedOff LoanStatus = "CHARGEDOFF"
+	StatusPaid       LoanStatus = "PAID"
+	StatusSold       LoanStatus = "SOLD"
+)
+
+// Status returns the status of the loan
+func (m *MicroLoan) Status() LoanStatus {
+	if m.Sold {
+		return StatusSold
+	} else if !m.ChargeOffDate.IsZero() {
+		return StatusChargedOff
+	} else if m.OutstandingPrincipal <= 0 {
+		return StatusPaid
+	} else if m.Late() {
+		return StatusLate
+	}
+	return StatusCurrent
+}
+
+// PortfolioRow summarizes the notes of a vintage month and grade.
+type PortfolioRow struct {
+	Vintage     string
+	Grade       string
+	Notes       int
+	Statuses    map[LoanStatus]int
+	Issued      USD
+	Outstanding USD
+	Losses      USD
+}
+
+// DefaultRate returns the percent of notes in the row which were charged off.
+func (r PortfolioRow) DefaultRate() float64 {
+	if r.Notes == 0 {
+		return 0
+	}
+	return float64(r.Statuses[StatusChargedOff]) / float64(r.Notes) * 100
+}
+
+// LossPoint is the cumulative loss of a vintage a number of months after issuance.
+type LossPoint struct {
+	Vintage string
+	Month   int
+	Issued  USD
+	Losses  USD
+}
+
+// PortfolioReport is a snapshot of the notes held by a peer-to-peer account.
+type PortfolioReport struct {
+	Date    time.Time
+	Account string
+	Rows    []PortfolioRow
+}
+
+// NewPortfolioReport summarizes the notes by vintage and grade.
+func NewPortfolioReport(date time.Time, acct *Peer2PeerAccount) PortfolioReport {
+	rows := map[string]*PortfolioRow{}
+	for _, loan := range acct.MicroLoans {
+		vintage := loan.StartDate.Format("2006-01")
+		key := vintage + loan.Grade
+		row, ok := rows[key]
+		if !ok {
+			row = &PortfolioRow{Vintage: vintage, Grade: loan.Grade, Statuses: map[LoanStatus]int{}}
+			rows[key] = row
+		}
+		row.Notes++
+		row.Statuses[loan.Status()]++
+		row.Issued += loan.Cost
+		row.Outstanding += loan.OutstandingPrincipal
+		row.Losses += loan.Loss
+	}
+
+	report := PortfolioReport{Date: date, Account: acct.Name}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Vintage == report.Rows[j].Vintage {
+			return report.Rows[i].Grade < report.Rows[j].Grade
+		}
+		return report.Rows[i].Vintage < report.Rows[j].Vintage
+	})
+	return report
+}
+
+// NewLossCurves computes the cumulative losses of each vintage for every month from issuance until the given date.
+func NewLossCurves(date time.Time, acct *Peer2PeerAccount) []LossPoint {
+	losses := map[string]map[int]USD{}
+	issued := map[string]USD{}
+	for _, loan := range acct.MicroLoans {
+		vintage := loan.StartDate.Format("2006-01")
+		issued[vintage] += loan.Cost
+		if _, ok := losses[vintage]; !ok {
+			losses[vintage] = map[int]USD{}
+		}
+		if loan.Loss > 0 {
+			losses[vintage][monthsBetween(loan.StartDate, loan.ChargeOffDate)] += loan.Loss
+		}
+	}
+
+	var vintages []string
+	for vintage := range losses {
+		vintages = append(vintages, vintage)
+	}
+	sort.Strings(vintages)
+
+	var curves []LossPoint
+	for _, vintage := range vintages {
+		start, _ := time.Parse("2006-01", vintage)
+		var total USD
+		for month := 0; month <= monthsBetween(start, date); month++ {
+			total += losses[vintage][month]
+			curves = append(curves, LossPoint{vintage, month, issued[vintage], total})
+		}
+	}
+	return curves
+}
+
+func monthsBetween(start, end time.Time) int {
+	return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+}
+
+// WritePortfolioHeader writes the CSV header for the portfolio rows.
+func WritePortfolioHeader(w io.Writer) {
+	io.WriteString(w, "date,account,vintage,grade,notes,current,late,chargedoff,paid,sold,issued,outstanding,losses,defaultrate\n")
+}
+
+// WriteCSV writes the portfolio rows as CSV.
+func (p PortfolioReport) WriteCSV(w io.Writer) {
+	for _, row := range p.Rows {
+		fmt.Fprintf(w, "%s,%s,%s,%s,%d,%d,%d,%d,%d,%d,%.2f,%.2f,%.2f,%.2f\n",
+			p.Date.Format("2006-01-02"),
+			p.Account,
+			row.Vintage,
+			row.Grade,
+			row.Notes,
+			row.Statuses[StatusCurrent],
+			row.Statuses[StatusLate],
+			row.Statuses[StatusChargedOff],
+			row.Statuses[StatusPaid],
+			row.Statuses[StatusSold],
+			float64(row.Issued)/100,
+			float64(row.Outstanding)/100,
+			float64(row.Losses)/100,
+			row.DefaultRate(),
+		)
+	}
+}
+
+// WriteLossCSV writes the cumulative loss curves as CSV.
+func WriteLossCSV(w io.Writer, curves []LossPoint) {
+	io.WriteString(w, "vintage,month,issued,losses,lossrate\n")
+	for _, point := range curves {
+		var rate float64
+		if point.Issued > 0 {
+			rate = float64(point.Losses) / float64(point.Issued) * 100
+		}
+		fmt.Fprintf(w, "%s,%d,%.2f,%.2f,%.4f\n",
+			point.Vintage,
+			point.Month,
+			float64(point.Issued)/100,
+			float64(point.Losses)/100,
+			rate,
+		)
+	}
+}
+
+// WritePortfolio writes the portfolio report and the loss curves of a peer-to-peer account to CSV files.
+func WritePortfolio(date time.Time, acct *Peer2PeerAccount, portfolioFile, lossFile string) error {
+	f, err := os.Create(portfolioFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	WritePortfolioHeader(f)
+	NewPortfolioReport(date, acct).WriteCSV(f)
+
+	l, err := os.Create(lossFile)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	WriteLossCSV(l, NewLossCurves(date, acct))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPortfolioReport(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(100), Dollars(25))
+	investNotes(acct, date, 2)
+
+	paid, chargedOff := acct.MicroLoans[0], acct.MicroLoans[1]
+	paid.OutstandingPrincipal = 0
+	chargedOff.LateSince = date
+	if err := chargedOff.chargeOff(date.AddDate(0, 4, 0), acct, &CreditRisk{RecoveryRate: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A seasoned note with $20 outstanding bought at a 5% discount
+	acct.investable = Dollars(50)
+	seasoned := newMicroLoan(2, NoteListing{Grade: "A", Rate: 7, Term: 36}, Dollars(25), date)
+	seasoned.OutstandingPrincipal = Dollars(20)
+	(&SecondaryMarket{}).buy(date, acct, seasoned, -5)
+
+	report := NewPortfolioReport(date.AddDate(0, 5, 0), acct)
+	if len(report.Rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(report.Rows))
+	}
+	row := report.Rows[0]
+	if row.Notes != 3 || row.Statuses[StatusPaid] != 1 || row.Statuses[StatusChargedOff] != 1 || row.Statuses[StatusCurrent] != 1 {
+		t.Errorf("statuses = %v", row.Statuses)
+	}
+
+	// Bought notes are issued at the purchase price rather than the original loan amount
+	if got, want := row.Issued, Dollars(50)+Dollars(19); got != want {
+		t.Errorf("issued = %s, want %s", got, want)
+	}
+	if got, want := row.Outstanding, Dollars(20); got != want {
+		t.Errorf("outstanding = %s, want %s", got, want)
+	}
+	if got, want := row.Losses, Dollars(25)-USD(250); got != want {
+		t.Errorf("losses = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Line item priorities. Lower priorities are processed first each day so that when cash is short,
+// income arrives before bills are paid and savings are only made from what is left.
+const (
+	PriorityIncome        = 0
+	PriorityDebt          = 10
+	PriorityBills         = 20
+	PriorityDiscretionary = 30
+	PrioritySavings       = 40
+)
+
+// Prioritized is implemented by line items with an explicit priority.
+type Prioritized interface {
+	Priority() int
+}
+
+// PriorityItem gives a line item an explicit priority.
+type PriorityItem struct {
+	Priority int
+	Item     LineItem
+}
+
+func (p *PriorityItem) Description() string {
+	return fmt.Sprintf("[%d] %s", p.Priority, p.Item.Description())
+}
+
+func (p *PriorityItem) Process(date time.Time, bank *Bank) error {
+	return p.Item.Process(date, bank)
+}
+
+// ItemPriority returns the priority of a line item. Line items without an explicit priority are
+// ordered by what they do: income, then loan payments, bills, discretionary spending and savings.
+func ItemPriority(item LineItem) int {
+	switch li := item.(type) {
+	case *PriorityItem:
+		return li.Priority
+	case Prioritized:
+		return li.Priority()
+	case *ConditionalItem:
+		return ItemPriority(li.Item)
+	case *MonthlyTransaction:
+		return typePriority(li.Type, PriorityBills)
+	case *OneTimeTransaction:
+		return typePriority(li.Type, PriorityBills)
+	case *DailyRandomTransaction:
+		return typePriority(li.Type, PriorityDiscretionary)
+	case *PropertySale, *SweepReturns:
+		return PriorityIncome
+	case *LoanPayment, *HouseholdLoanPayment, *EarlyPayoff:
+		return PriorityDebt
+	case *PropertyMaintenance:
+		return PriorityBills
+	case *MonthlyTransfer, *Sweep:
+		return PrioritySavings
+	}
+	return PriorityBills
+}
+
+// typePriority returns the income priority for deposits and the given priority for withdrawals.
+func typePriority(kind TransactionType, withdrawal int) int {
+	if kind == Deposit {
+		return PriorityIncome
+	}
+	return withdrawal
+}
+
+// sortLineItems orders the line items by priority. Line items with the same priority keep their order.
+func (b *Bank) sortLineItems() {
+	sort.SliceStable(b.LineItems, func(i, j int) bool {
+		return ItemPriority(b.LineItems[i]) < ItemPriority(b.LineItems[j])
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLineItemPriority(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := date.AddDate(1, 0, 0)
+	checking := NewBankAccount("Checking", date, 0)
+	savings := NewBankAccount("Savings", date, 0)
+	loan := NewLoan("Mortgage", Dollars(20000), 4, 5, 0)
+
+	// Line items are listed in the reverse of the order they should be paid
+	bank := &Bank{
+		Accounts: map[string]Account{"Checking": checking, "Savings": savings, "Mortgage": loan},
+		LineItems: []LineItem{
+			&MonthlyTransfer{From: "Checking", To: "Savings", Amount: Dollars(500), DayOfMonth: 1, StartDate: date, EndDate: end},
+			&PriorityItem{Priority: PriorityDiscretionary, Item: &MonthlyTransaction{Account: "Checking", Name: "Dining", Type: Withdrawal, Amount: Dollars(400), DayOfMonth: 1, StartDate: date, EndDate: end}},
+			&MonthlyTransaction{Account: "Checking", Name: "Rent", Type: Withdrawal, Amount: Dollars(1000), DayOfMonth: 1, StartDate: date, EndDate: end},
+			&MonthlyTransaction{Account: "Checking", Name: "Electric", Type: Withdrawal, Amount: Dollars(700), DayOfMonth: 1, StartDate: date, EndDate: end},
+			&LoanPayment{From: "Checking", To: "Mortgage", DayOfMonth: 1},
+			&MonthlyTransaction{Account: "Checking", Name: "Salary", Type: Deposit, Amount: Dollars(2000), DayOfMonth: 1, StartDate: date, EndDate: end},
+		},
+	}
+	proc := NewDefaultProcess(ctx, "Bank Process", bank, ProcessList{})
+	bank.Handle(ctx, proc, Message{Type: MessageTypeStart})
+	bank.Handle(ctx, proc, Message{Type: TypeDate, Value: date})
+
+	// The salary covers the loan payment and rent. Electric was added after rent with the same priority,
+	// so it is the bill which goes unpaid. Dining is paid from what is left and nothing is saved.
+	var paid []string
+	for _, tx := range checking.Ledger[1:] {
+		paid = append(paid, tx.Description)
+	}
+	want := []string{"Salary", "Transfer from 'Checking' to 'Mortgage'", "Rent", "Dining"}
+	if len(paid) != len(want) {
+		t.Fatalf("paid %q, want %q", paid, want)
+	}
+	for i := range want {
+		if paid[i] != want[i] {
+			t.Errorf("payment %d = %q, want %q", i, paid[i], want[i])
+		}
+	}
+	if savings.Balance != 0 {
+		t.Errorf("savings = %s", savings.Balance)
+	}
+	if left := Dollars(2000) - loan.MonthlyPayment - Dollars(1400); checking.Balance != left {
+		t.Errorf("checking = %s, want %s", checking.Balance, left)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// AppreciationModel determines the annual appreciation rate of a property.
+type AppreciationModel interface {
+	AnnualRate(date time.Time) float64
+}
+
+// FixedAppreciation appreciates a property at a constant annual percentage.
+type FixedAppreciation struct {
+	Rate float64
+}
+
+// AnnualRate returns the fixed appreciation rate.
+func (f *FixedAppreciation) AnnualRate(date time.Time) float64 {
+	return f.Rate
+}
+
+// StochasticAppreciation draws a normally distributed annual appreciation rate once per year.
+type StochasticAppreciation struct {
+	Mean   float64
+	StdDev float64
+
+	year int
+	rate float64
+}
+
+// AnnualRate returns the appreciation rate drawn for the year of the given date.
+func (s *StochasticAppreciation) AnnualRate(date time.Time) float64 {
+	if date.Year() != s.year {
+		s.year = date.Year()
+		s.rate = s.Mean + s.StdDev*rand.NormFloat64()
+	}
+	return s.rate
+}
+
+// NewPropertyAccount creates a new real estate account financed by the given loan account.
+func NewPropertyAccount(name string, date time.Time, price USD, model AppreciationModel, loan string) *PropertyAccount {
+	return &PropertyAccount{
+		Name:          name,
+		PurchasePrice: price,
+		Value:         price,
+		Appreciation:  model,
+		Loan:          loan,
+		Ledger: []Transaction{
+			Transaction{Date: date, Description: "Purchase price", Type: Deposit, Amount: price, Balance: price},
+		},
+	}
+}
+
+// PropertyAccount represents a home or other real estate asset.
+type PropertyAccount struct {
+	Name          string
+	PurchasePrice USD
+	Value         USD
+	Appreciation  AppreciationModel
+	Loan          string
+	Sold          bool
+	Ledger        []Transaction
+}
+
+// CurrentBalance returns the current market value of the property
+func (a *PropertyAccount) CurrentBalance() USD {
+	return a.Value
+}
+
+// Transactions returns the account ledger
+func (a *PropertyAccount) Transactions() []Transaction {
+	return a.Ledger
+}
+
+// Equity returns the market value less the remaining loan principal.
+func (a *PropertyAccount) Equity(bank *Bank) USD {
+	return a.Value - a.loanBalance(bank)
+}
+
+func (a *PropertyAccount) loanBalance(bank *Bank) USD {
+	if a.Loan == "" {
+		return 0
+	}
+	loan, ok := bank.Accounts[a.Loan].(*LoanAccount)
+	if !ok {
+		return 0
+	}
+	return loan.PayoffAmount()
+}
+
+// Update appreciates the property on the first of each month.
+func (a *PropertyAccount) Update(ctx context.Context, proc Process, bank *Bank, date time.Time) {
+	if a.Sold {
+		return
+	}
+
+	if date.Day() == 1 && a.Appreciation != nil {
+		rate := a.Appreciation.AnnualRate(date)
+		a.Value = a.Value.Mul(math.Pow(1+rate/100., 1./12.), RoundHalfEven)
+	}
+}
+
+// Append appends a transaction to the account. Deposits are capital improvements and withdrawals are sales.
+func (a *PropertyAccount) Append(tx Transaction) error {
+	log.Println(a.Name, tx)
+	if tx.Type == Deposit {
+		a.Value += tx.Amount
+	} else if tx.Type == Withdrawal {
+		if tx.Amount > a.Value {
+			return ErrInsufficientFunds
+		}
+		a.Value -= tx.Amount
+	} else {
+		return ErrUnknownTransactionType
+	}
+	tx.Balance = a.Value
+	a.Ledger = append(a.Ledger, tx)
+	return nil
+}
+
+// Validate validates a transaction. Property cannot be transferred in or out of.
+func (a *PropertyAccount) Validate(tx Transaction) bool {
+	return false
+}
+
+// String returns the string representation of the account
+func (a *PropertyAccount) String() string {
+	return fmt.Sprintf("%s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%t\n",
+		a.Name, a.Value,
+		"Purchase Price:", a.PurchasePrice,
+		"Loan:\t\t", a.Loan,
+		"Sold:\t\t", a.Sold,
+	)
+}
+
+func getProperty(bank *Bank, name string) (*PropertyAccount, error) {
+	acct, ok := bank.Accounts[name]
+	if !ok {
+		return nil, ErrAccountDoesNotExist
+	}
+
+	property, ok := acct.(*PropertyAccount)
+	if !ok {
+		return nil, ErrInvalidTransfer
+	}
+	return property, nil
+}
+
+// PropertyMaintenance is a monthly expense for upkeep as an annual percentage of the property value.
+type PropertyMaintenance struct {
+	Property   string
+	From       string
+	Rate       float64
+	DayOfMonth int
+}
+
+func (p *PropertyMaintenance) Description() string {
+	return fmt.Sprintf("MAINTENANCE %s from %s\t%.2f%%", p.Property, p.From, p.Rate)
+}
+
+func (p *PropertyMaintenance) Process(date time.Time, bank *Bank) error {
+	if date.Day() != p.DayOfMonth {
+		return nil
+	}
+
+	property, err := getProperty(bank, p.Property)
+	if err != nil {
+		return err
+	}
+	if property.Sold {
+		return nil
+	}
+
+	amount := property.Value.Percent(p.Rate/12., RoundHalfEven)
+	desc := fmt.Sprintf("Maintenance for '%s'", p.Property)
+	return bank.Append(p.From, Transaction{Date: date, Type: Withdrawal, Description: desc, Amount: amount})
+}
+
+// PropertySale sells a property, pays off the linked loan, deducts the selling costs
+// and deposits the proceeds.
+type PropertySale struct {
+	Property     string
+	To           string
+	SellingCosts float64
+	Date         time.Time
+}
+
+func (p *PropertySale) Description() string {
+	return fmt.Sprintf("SALE %s to %s", p.Property, p.To)
+}
+
+func (p *PropertySale) Process(date time.Time, bank *Bank) error {
+	if !equalDates(date, p.Date) {
+		return nil
+	}
+
+	property, err := getProperty(bank, p.Property)
+	if err != nil {
+		return err
+	}
+	if property.Sold {
+		return nil
+	}
+
+	price := property.Value
+	costs := price.Percent(p.SellingCosts, RoundHalfEven)
+	desc := fmt.Sprintf("Sale of '%s'", p.Property)
+
+	// Validate the loan payoff and the proceeds before the property is sold
+	var loan *LoanAccount
+	var payoff USD
+	if property.Loan != "" {
+		acct, ok := bank.Accounts[property.Loan]
+		if !ok {
+			return ErrAccountDoesNotExist
+		}
+		if loan, ok = acct.(*LoanAccount); !ok {
+			return ErrInvalidTransfer
+		}
+		payoff = loan.PayoffAmount()
+	}
+	payoffTx := Transaction{Date: date, Type: Payoff, Description: desc, Amount: payoff}
+	if payoff > 0 && !loan.Validate(payoffTx) {
+		return ErrInvalidTransfer
+	}
+
+	to, ok := bank.Accounts[p.To]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+
+	// Underwater sales are covered by the receiving account
+	proceedsTx := Transaction{Date: date, Type: Deposit, Description: desc, Amount: price - costs - payoff}
+	if proceedsTx.Amount < 0 {
+		proceedsTx.Type, proceedsTx.Amount = Withdrawal, -proceedsTx.Amount
+	}
+	if !to.Validate(proceedsTx) {
+		return ErrInsufficientFunds
+	}
+
+	if err := property.Append(Transaction{Date: date, Type: Withdrawal, Description: desc, Amount: price}); err != nil {
+		return err
+	}
+	property.Sold = true
+	bank.Emit(date, p.Property, "SALE", desc)
+
+	// Pay off the linked loan from the sale price
+	if payoff > 0 {
+		if err := loan.Append(payoffTx); err != nil {
+			return err
+		}
+	}
+	return to.Append(proceedsTx)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newPropertyBank(value, loan, cash USD) *Bank {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Bank{
+		Accounts: map[string]Account{
+			"Checking": NewBankAccount("Checking", start, cash),
+			"Mortgage": NewLoan("Mortgage", loan, 4, 30, 0),
+			"Home":     NewPropertyAccount("Home", start, value, nil, "Mortgage"),
+		},
+	}
+}
+
+func TestPropertySale(t *testing.T) {
+	date := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	bank := newPropertyBank(Dollars(300000), Dollars(200000), Dollars(1000))
+	sale := &PropertySale{Property: "Home", To: "Checking", SellingCosts: 6, Date: date}
+	if err := sale.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+
+	home := bank.Accounts["Home"].(*PropertyAccount)
+	loan := bank.Accounts["Mortgage"].(*LoanAccount)
+	if !home.Sold || home.Value != 0 {
+		t.Errorf("home not sold: %v %s", home.Sold, home.Value)
+	}
+	if loan.PayoffAmount() != 0 {
+		t.Errorf("loan not paid off: %s", loan.PayoffAmount())
+	}
+
+	// 300,000 - 18,000 selling costs - 200,000 payoff
+	if got, want := bank.Accounts["Checking"].CurrentBalance(), Dollars(1000+82000); got != want {
+		t.Errorf("checking = %s, want %s", got, want)
+	}
+}
+
+func TestPropertySaleUnderwater(t *testing.T) {
+	date := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	bank := newPropertyBank(Dollars(200000), Dollars(200000), Dollars(1000))
+	sale := &PropertySale{Property: "Home", To: "Checking", SellingCosts: 6, Date: date}
+	if err := sale.Process(date, bank); err != ErrInsufficientFunds {
+		t.Fatalf("err = %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	// Nothing changes when the shortfall cannot be covered
+	home := bank.Accounts["Home"].(*PropertyAccount)
+	loan := bank.Accounts["Mortgage"].(*LoanAccount)
+	if home.Sold || home.Value != Dollars(200000) {
+		t.Errorf("home sold: %v %s", home.Sold, home.Value)
+	}
+	if loan.PayoffAmount() != Dollars(200000) || len(loan.Ledger) != 0 {
+		t.Errorf("loan changed: %s %d", loan.PayoffAmount(), len(loan.Ledger))
+	}
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(1000) {
+		t.Errorf("checking = %s", got)
+	}
+}
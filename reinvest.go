@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ReinvestmentPolicy decides how much of the available cash of a peer-to-peer account is invested in new notes.
+type ReinvestmentPolicy interface {
+	Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD
+}
+
+// ReinvestAll invests all of the available cash.
+type ReinvestAll struct{}
+
+// Investable returns the available cash.
+func (r *ReinvestAll) Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD {
+	return acct.AvailableCash
+}
+
+// SweepReturns is a monthly line item which transfers the net returns of a peer-to-peer account to another
+// account, so only the principal is reinvested. Net returns are the interest earned less late fees and
+// charge-off losses. Losses larger than the interest are made up before anything more is swept.
+type SweepReturns struct {
+	From       string
+	To         string
+	DayOfMonth int
+
+	swept USD
+}
+
+func (s *SweepReturns) Description() string {
+	return fmt.Sprintf("SWEEP RETURNS %s to %s", s.From, s.To)
+}
+
+// Process transfers the net returns earned since the last sweep.
+func (s *SweepReturns) Process(date time.Time, bank *Bank) error {
+	if date.Day() != s.DayOfMonth {
+		return nil
+	}
+
+	acct, ok := bank.Accounts[s.From].(*Peer2PeerAccount)
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+
+	amount := acct.Interest - acct.LateFees - acct.ChargeOffs - s.swept
+	if amount >= acct.AvailableCash {
+		amount = acct.AvailableCash - 1
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	if err := bank.Transfer(date, s.From, s.To, amount); err != nil {
+		return err
+	}
+	s.swept += amount
+	return nil
+}
+
+// CashReserve keeps a percentage of the account value as cash.
+type CashReserve struct {
+	Percent float64
+}
+
+// Investable returns the available cash above the reserve.
+func (c *CashReserve) Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD {
+	reserve := acct.AccountValue.Percent(c.Percent, RoundHalfEven)
+	return acct.AvailableCash - reserve
+}
+
+// ExposureCap limits the total outstanding principal.
+type ExposureCap struct {
+	Max USD
+}
+
+// Investable returns the available cash up to the cap.
+func (e *ExposureCap) Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD {
+	if room := e.Max - acct.OutstandingPrincipal; room < acct.AvailableCash {
+		return room
+	}
+	return acct.AvailableCash
+}
+
+// WindDown stops reinvesting after a date so the notes are paid off and the account turns into cash.
+type WindDown struct {
+	Date time.Time
+}
+
+// Investable returns nothing after the wind-down date.
+func (w *WindDown) Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD {
+	if date.Before(w.Date) {
+		return acct.AvailableCash
+	}
+	return 0
+}
+
+// ReinvestmentPolicies combines policies, investing the smallest amount any of them allow.
+type ReinvestmentPolicies []ReinvestmentPolicy
+
+// Investable applies every policy and returns the smallest investable amount.
+func (p ReinvestmentPolicies) Investable(date time.Time, bank *Bank, acct *Peer2PeerAccount) USD {
+	investable := USD(math.MaxInt64)
+	for _, policy := range p {
+		if amount := policy.Investable(date, bank, acct); amount < investable {
+			investable = amount
+		}
+	}
+	if investable > acct.AvailableCash {
+		return acct.AvailableCash
+	}
+	return investable
+}
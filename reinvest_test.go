@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReinvestmentPolicies(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(1000), Dollars(25))
+	acct.AccountValue = Dollars(5000)
+	acct.OutstandingPrincipal = Dollars(4000)
+
+	tests := []struct {
+		name   string
+		policy ReinvestmentPolicy
+		date   time.Time
+		want   USD
+	}{
+		{"all", &ReinvestAll{}, date, Dollars(1000)},
+		{"reserve", &CashReserve{Percent: 3}, date, Dollars(850)},
+		{"cap room", &ExposureCap{Max: Dollars(4500)}, date, Dollars(500)},
+		{"cap above cash", &ExposureCap{Max: Dollars(6000)}, date, Dollars(1000)},
+		{"cap reached", &ExposureCap{Max: Dollars(3000)}, date, -Dollars(1000)},
+		{"before wind down", &WindDown{Date: date.AddDate(0, 0, 1)}, date, Dollars(1000)},
+		{"wind down", &WindDown{Date: date}, date, 0},
+		{"smallest", ReinvestmentPolicies{&ReinvestAll{}, &CashReserve{Percent: 3}, &ExposureCap{Max: Dollars(4900)}}, date, Dollars(850)},
+		{"combined wind down", ReinvestmentPolicies{&CashReserve{Percent: 3}, &WindDown{Date: date}}, date, 0},
+		{"no policies", ReinvestmentPolicies{}, date, Dollars(1000)},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Investable(tt.date, nil, acct); got != tt.want {
+			t.Errorf("%s: investable = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if acct.AvailableCash != Dollars(1000) {
+		t.Errorf("policies changed the available cash to %s", acct.AvailableCash)
+	}
+}
+
+func TestSweepReturns(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewPeer2PeerAccount("Investment", date, Dollars(1000), Dollars(25))
+	bank := &Bank{Accounts: map[string]Account{
+		"Investment": acct,
+		"Checking":   NewBankAccount("Checking", date, 0),
+	}}
+	sweep := &SweepReturns{From: "Investment", To: "Checking", DayOfMonth: 1}
+
+	// Each month adds interest, late fees and losses, and the net returns since the last sweep are swept
+	months := []struct {
+		interest, lateFees, chargeOffs USD
+		swept                          USD
+	}{
+		{Dollars(100), Dollars(15), Dollars(25), Dollars(60)},
+		{Dollars(50), 0, Dollars(80), 0},
+		{Dollars(40), 0, 0, Dollars(10)},
+	}
+	for i, m := range months {
+		acct.Interest += m.interest
+		acct.LateFees += m.lateFees
+		acct.ChargeOffs += m.chargeOffs
+
+		before := bank.Accounts["Checking"].CurrentBalance()
+		if err := sweep.Process(date.AddDate(0, i, 0), bank); err != nil {
+			t.Fatal(err)
+		}
+		if got := bank.Accounts["Checking"].CurrentBalance() - before; got != m.swept {
+			t.Errorf("month %d: swept %s, want %s", i+1, got, m.swept)
+		}
+	}
+
+	// Sweeps are only made on the day of the month
+	acct.Interest += Dollars(100)
+	if err := sweep.Process(date.AddDate(0, 3, 1), bank); err != nil {
+		t.Fatal(err)
+	}
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(70) {
+		t.Errorf("checking = %s, want %s", got, Dollars(70))
+	}
+
+	if err := (&SweepReturns{From: "Checking", To: "Investment", DayOfMonth: 1}).Process(date, bank); err != ErrAccountDoesNotExist {
+		t.Errorf("err = %v, want %v", err, ErrAccountDoesNotExist)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// chartColors are the line colors used by the charts
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// Chart dimensions
+const (
+	chartWidth  = 900.
+	chartHeight = 320.
+	chartMargin = 70.
+)
+
+// SeriesPoint is the value of a series on a date.
+type SeriesPoint struct {
+	Date  time.Time
+	Value USD
+}
+
+// cashFlowMonth is the cash flow of an account during a month.
+type cashFlowMonth struct {
+	Month   time.Time
+	Start   USD
+	Inflow  USD
+	Outflow USD
+	End     USD
+}
+
+// NewHTMLReport creates a report which is written to the writer as a self-contained HTML page
+// when the sink is closed. The cash flow waterfall is drawn for the given cash account.
+func NewHTMLReport(w io.Writer, title, cashAccount string) *HTMLReport {
+	return &HTMLReport{
+		Title:       title,
+		CashAccount: cashAccount,
+		w:           w,
+		kinds:       map[string]string{},
+		balances:    map[string][]AccountInfo{},
+		last:        map[string]AccountInfo{},
+	}
+}
+
+// HTMLReport is a sink which draws the account balance snapshots and transactions as SVG charts.
+// Balances are sampled on the first of each month.
+type HTMLReport struct {
+	Title       string
+	CashAccount string
+
+	// Runs holds the net worth of other Monte Carlo runs. If there are any, a fan chart of
+	// the percentile bands across the runs is included.
+	Runs [][]SeriesPoint
+
+	w        io.Writer
+	names    []string
+	kinds    map[string]string
+	balances map[string][]AccountInfo
+	last     map[string]AccountInfo
+	cashFlow []cashFlowMonth
+}
+
+// Account records the account type.
+func (r *HTMLReport) Account(acct AccountDescription) error {
+	if _, ok := r.kinds[acct.Name]; !ok {
+		r.names = append(r.names, acct.Name)
+	}
+	r.kinds[acct.Name] = acct.Kind
+	return nil
+}
+
+// Snapshot records the end of day balances on the first of each month.
+func (r *HTMLReport) Snapshot(info AccountInfo) error {
+	if info.Period != PeriodBalance {
+		return nil
+	}
+	if info.Date.Day() == 1 {
+		r.balances[info.Account] = append(r.balances[info.Account], info)
+	}
+	r.last[info.Account] = info
+	return nil
+}
+
+// Transaction adds the deposits and withdrawals of the cash account to the monthly cash flow.
+func (r *HTMLReport) Transaction(tx AccountTransaction) error {
+	if tx.Account != r.CashAccount || (tx.Type != Deposit && tx.Type != Withdrawal) {
+		return nil
+	}
+
+	month := time.Date(tx.Date.Year(), tx.Date.Month(), 1, 0, 0, 0, 0, tx.Date.Location())
+	if len(r.cashFlow) == 0 || !r.cashFlow[len(r.cashFlow)-1].Month.Equal(month) {
+		// The cash account is never a loan
+		r.cashFlow = append(r.cashFlow, cashFlowMonth{Month: month, Start: tx.Balance - signedAmount(nil, tx.Transaction)})
+	}
+
+	cf := &r.cashFlow[len(r.cashFlow)-1]
+	if tx.Type == Deposit {
+		cf.Inflow += tx.Amount
+	} else {
+		cf.Outflow += tx.Amount
+	}
+	cf.End = tx.Balance
+	return nil
+}
+
+// Event is a no-op for the HTML report.
+func (r *HTMLReport) Event(e Event) error {
+	return nil
+}
+
+// series returns the monthly balances of an account along with its final balance.
+func (r *HTMLReport) series(name string) []AccountInfo {
+	points := r.balances[name]
+	if last, ok := r.last[name]; ok && (len(points) == 0 || !points[len(points)-1].Date.Equal(last.Date)) {
+		points = append(points, last)
+	}
+	return points
+}
+
+// NetWorth returns the sum of the account values each month.
+func (r *HTMLReport) NetWorth() []SeriesPoint {
+	totals := map[time.Time]USD{}
+	for _, name := range r.names {
+		for _, info := range r.series(name) {
+			totals[info.Date] += info.AccountValue
+		}
+	}
+
+	var points []SeriesPoint
+	for date, value := range totals {
+		points = append(points, SeriesPoint{date, value})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	return points
+}
+
+// Close writes the report.
+func (r *HTMLReport) Close() error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title>\n", html.EscapeString(r.Title))
+	b.WriteString("<style>body{font-family:sans-serif;margin:2em;color:#333}svg{background:#fafafa;border:1px solid #ddd}" +
+		"text{font-size:11px;fill:#555}.grid{stroke:#e5e5e5}.axis{stroke:#999}</style></head><body>\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(r.Title))
+
+	netWorth := r.NetWorth()
+	b.WriteString("<h2>Net Worth</h2>\n")
+	b.WriteString(lineChart([]chartSeries{seriesFromPoints("Net Worth", netWorth)}))
+
+	if len(r.Runs) > 0 {
+		b.WriteString("<h2>Net Worth Percentiles</h2>\n")
+		b.WriteString(fanChart(append(r.Runs, netWorth)))
+	}
+
+	b.WriteString("<h2>Account Balances</h2>\n")
+	for _, name := range r.names {
+		var series []chartSeries
+		points := r.series(name)
+		if r.kinds[name] == "LoanAccount" {
+			continue
+		}
+		series = append(series, seriesFromInfo("Value", points, func(info AccountInfo) USD { return info.AccountValue }))
+		if r.kinds[name] == "Peer2PeerAccount" {
+			series = append(series, seriesFromInfo("Cash", points, func(info AccountInfo) USD { return info.AvailableCash }))
+		}
+		fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(name))
+		b.WriteString(lineChart(series))
+	}
+
+	if len(r.cashFlow) > 0 {
+		fmt.Fprintf(&b, "<h2>Monthly Cash Flow: %s</h2>\n", html.EscapeString(r.CashAccount))
+		b.WriteString(waterfallChart(r.cashFlow))
+	}
+
+	for _, name := range r.names {
+		if r.kinds[name] != "LoanAccount" {
+			continue
+		}
+		points := r.series(name)
+		fmt.Fprintf(&b, "<h2>Loan Amortization: %s</h2>\n", html.EscapeString(name))
+		b.WriteString(lineChart([]chartSeries{
+			seriesFromInfo("Principal Outstanding", points, func(info AccountInfo) USD { return -info.AccountValue }),
+			seriesFromInfo("Principal Paid", points, func(info AccountInfo) USD { return info.CashFlow }),
+			seriesFromInfo("Interest Paid", points, func(info AccountInfo) USD { return info.Interest }),
+		}))
+	}
+
+	b.WriteString("</body></html>\n")
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+// chartSeries is a named series of monthly values
+type chartSeries struct {
+	Name   string
+	Dates  []time.Time
+	Values []float64
+}
+
+func seriesFromPoints(name string, points []SeriesPoint) chartSeries {
+	s := chartSeries{Name: name}
+	for _, p := range points {
+		s.Dates = append(s.Dates, p.Date)
+		s.Values = append(s.Values, p.Value.Float64())
+	}
+	return s
+}
+
+func seriesFromInfo(name string, points []AccountInfo, value func(AccountInfo) USD) chartSeries {
+	s := chartSeries{Name: name}
+	for _, p := range points {
+		s.Dates = append(s.Dates, p.Date)
+		s.Values = append(s.Values, value(p).Float64())
+	}
+	return s
+}
+
+// shortDollars formats a dollar amount for axis labels.
+func shortDollars(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1e6:
+		return fmt.Sprintf("$%.1fM", v/1e6)
+	case a >= 1e3:
+		return fmt.Sprintf("$%.0fk", v/1e3)
+	}
+	return fmt.Sprintf("$%.0f", v)
+}
+
+// chartScale maps dates and dollar values onto the chart area.
+type chartScale struct {
+	start, end time.Time
+	min, max   float64
+}
+
+func newChartScale(dates []time.Time, values ...[]float64) chartScale {
+	s := chartScale{min: 0, max: 1}
+	if len(dates) > 0 {
+		s.start, s.end = dates[0], dates[len(dates)-1]
+	}
+	for _, vals := range values {
+		for _, v := range vals {
+			s.min = math.Min(s.min, v)
+			s.max = math.Max(s.max, v)
+		}
+	}
+	return s
+}
+
+func (s chartScale) x(date time.Time) float64 {
+	span := s.end.Sub(s.start).Hours()
+	if span <= 0 {
+		return chartMargin
+	}
+	return chartMargin + date.Sub(s.start).Hours()/span*(chartWidth-2*chartMargin)
+}
+
+func (s chartScale) y(v float64) float64 {
+	return chartHeight - chartMargin/2 - (v-s.min)/(s.max-s.min)*(chartHeight-chartMargin)
+}
+
+// axes draws the grid lines with dollar labels and the first and last dates.
+func (s chartScale) axes(b *strings.Builder) {
+	for i := 0; i <= 4; i++ {
+		v := s.min + (s.max-s.min)*float64(i)/4
+		fmt.Fprintf(b, "<line class=\"grid\" x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\"/>", chartMargin, s.y(v), chartWidth-chartMargin, s.y(v))
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\">%s</text>", chartMargin-6, s.y(v)+4, shortDollars(v))
+	}
+	if s.min < 0 {
+		fmt.Fprintf(b, "<line class=\"axis\" x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\"/>", chartMargin, s.y(0), chartWidth-chartMargin, s.y(0))
+	}
+	fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\">%s</text>", chartMargin, chartHeight-8, s.start.Format("2006-01"))
+	fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"end\">%s</text>", chartWidth-chartMargin, chartHeight-8, s.end.Format("2006-01"))
+}
+
+func svgOpen(b *strings.Builder) {
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\">", chartWidth, chartHeight)
+}
+
+// lineChart draws the series as lines with a legend.
+func lineChart(series []chartSeries) string {
+	var dates []time.Time
+	var values [][]float64
+	for _, s := range series {
+		dates = append(dates, s.Dates...)
+		values = append(values, s.Values)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	scale := newChartScale(dates, values...)
+
+	var b strings.Builder
+	svgOpen(&b)
+	scale.axes(&b)
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		var pts []string
+		for j, v := range s.Values {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", scale.x(s.Dates[j]), scale.y(v)))
+		}
+		fmt.Fprintf(&b, "<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\" points=\"%s\"/>", color, strings.Join(pts, " "))
+		fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%d\" width=\"10\" height=\"10\" fill=\"%s\"/><text x=\"%.1f\" y=\"%d\">%s</text>",
+			chartMargin+float64(i)*160, 8, color, chartMargin+float64(i)*160+14, 17, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// percentile returns the p-th percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Round(p / 100 * float64(len(sorted)-1)))
+	return sorted[i]
+}
+
+// fanChart draws the 10-90 and 25-75 percentile bands and the median of the runs for each month.
+func fanChart(runs [][]SeriesPoint) string {
+	byDate := map[time.Time][]float64{}
+	for _, run := range runs {
+		for _, p := range run {
+			byDate[p.Date] = append(byDate[p.Date], p.Value.Float64())
+		}
+	}
+
+	var dates []time.Time
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	bands := map[float64][]float64{}
+	for _, date := range dates {
+		values := byDate[date]
+		sort.Float64s(values)
+		for _, p := range []float64{10, 25, 50, 75, 90} {
+			bands[p] = append(bands[p], percentile(values, p))
+		}
+	}
+	scale := newChartScale(dates, bands[10], bands[90])
+
+	band := func(lo, hi []float64, fill string) string {
+		var pts []string
+		for i, date := range dates {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", scale.x(date), scale.y(hi[i])))
+		}
+		for i := len(dates) - 1; i >= 0; i-- {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", scale.x(dates[i]), scale.y(lo[i])))
+		}
+		return fmt.Sprintf("<polygon fill=\"%s\" points=\"%s\"/>", fill, strings.Join(pts, " "))
+	}
+
+	var b strings.Builder
+	svgOpen(&b)
+	scale.axes(&b)
+	b.WriteString(band(bands[10], bands[90], "#c6dbef"))
+	b.WriteString(band(bands[25], bands[75], "#6baed6"))
+	var pts []string
+	for i, date := range dates {
+		pts = append(pts, fmt.Sprintf("%.1f,%.1f", scale.x(date), scale.y(bands[50][i])))
+	}
+	fmt.Fprintf(&b, "<polyline fill=\"none\" stroke=\"#08306b\" stroke-width=\"1.5\" points=\"%s\"/>", strings.Join(pts, " "))
+	fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"17\">Median, 25-75th and 10-90th percentiles of %d runs</text>", chartMargin, len(runs))
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// waterfallChart draws each month as a rise from the starting balance by the inflows and a fall by the outflows.
+func waterfallChart(months []cashFlowMonth) string {
+	var dates []time.Time
+	var values []float64
+	for _, m := range months {
+		dates = append(dates, m.Month)
+		values = append(values, m.Start.Float64(), (m.Start + m.Inflow).Float64(), m.End.Float64())
+	}
+	scale := newChartScale(dates, values)
+	width := math.Max((chartWidth-2*chartMargin)/float64(len(months))/2, 0.5)
+
+	rect := func(x, from, to float64, fill string) string {
+		top, bottom := scale.y(math.Max(from, to)), scale.y(math.Min(from, to))
+		return fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.2f\" height=\"%.1f\" fill=\"%s\"/>", x, top, width, math.Max(bottom-top, 0.5), fill)
+	}
+
+	var b strings.Builder
+	svgOpen(&b)
+	scale.axes(&b)
+	for _, m := range months {
+		x := scale.x(m.Month)
+		peak := (m.Start + m.Inflow).Float64()
+		b.WriteString(rect(x, m.Start.Float64(), peak, "#2ca02c"))
+		b.WriteString(rect(x+width, peak, m.End.Float64(), "#d62728"))
+	}
+	fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"17\">Inflows (green) and outflows (red) from the starting balance of each month</text>", chartMargin)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
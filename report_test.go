@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetWorth(t *testing.T) {
+	r := NewHTMLReport(nil, "", "Checking")
+	r.Account(AccountDescription{Name: "Checking", Kind: "BankAccount"})
+	r.Account(AccountDescription{Name: "Mortgage", Kind: "LoanAccount"})
+
+	// Balances are sampled on the first of each month and on the last day
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, day := range []struct {
+		date           time.Time
+		checking, owed USD
+	}{
+		{jan, Dollars(1000), Dollars(500)},
+		{jan.AddDate(0, 0, 14), Dollars(9999), Dollars(9999)},
+		{jan.AddDate(0, 1, 0), Dollars(1500), Dollars(400)},
+		{jan.AddDate(0, 1, 9), Dollars(1700), Dollars(300)},
+	} {
+		r.Snapshot(AccountInfo{Date: day.date, Account: "Checking", Period: PeriodBalance, AccountValue: day.checking})
+		r.Snapshot(AccountInfo{Date: day.date, Account: "Mortgage", Period: PeriodBalance, AccountValue: -day.owed})
+		r.Snapshot(AccountInfo{Date: day.date, Account: "Checking", Period: PeriodMonthly, AccountValue: Dollars(1000000)})
+	}
+
+	want := []SeriesPoint{
+		{jan, Dollars(500)},
+		{jan.AddDate(0, 1, 0), Dollars(1100)},
+		{jan.AddDate(0, 1, 9), Dollars(1400)},
+	}
+	if got := r.NetWorth(); !reflect.DeepEqual(got, want) {
+		t.Errorf("net worth = %v, want %v", got, want)
+	}
+}
+
+func TestCashFlow(t *testing.T) {
+	r := NewHTMLReport(nil, "", "Checking")
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	txs := []AccountTransaction{
+		{"Checking", Transaction{Date: jan.AddDate(0, 0, 4), Type: Deposit, Amount: Dollars(300), Balance: Dollars(1300)}},
+		{"Checking", Transaction{Date: jan.AddDate(0, 0, 9), Type: Withdrawal, Amount: Dollars(100), Balance: Dollars(1200)}},
+		{"Savings", Transaction{Date: jan.AddDate(0, 0, 9), Type: Deposit, Amount: Dollars(100), Balance: Dollars(100)}},
+		{"Checking", Transaction{Date: jan.AddDate(0, 0, 20), Type: Deposit, Amount: Dollars(50), Balance: Dollars(1250)}},
+		{"Checking", Transaction{Date: jan.AddDate(0, 1, 2), Type: Withdrawal, Amount: Dollars(400), Balance: Dollars(850)}},
+	}
+	for _, tx := range txs {
+		r.Transaction(tx)
+	}
+
+	// Each month starts from the balance before its first transaction
+	want := []cashFlowMonth{
+		{jan, Dollars(1000), Dollars(350), Dollars(100), Dollars(1250)},
+		{jan.AddDate(0, 1, 0), Dollars(1250), 0, Dollars(400), Dollars(850)},
+	}
+	if !reflect.DeepEqual(r.cashFlow, want) {
+		t.Errorf("cash flow = %v, want %v", r.cashFlow, want)
+	}
+}
+
+func TestWaterfallChart(t *testing.T) {
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	months := []cashFlowMonth{
+		{jan, Dollars(1000), Dollars(350), Dollars(100), Dollars(1250)},
+		{jan.AddDate(0, 1, 0), Dollars(1250), 0, Dollars(400), Dollars(850)},
+	}
+	chart := waterfallChart(months)
+
+	// Each month rises from its start by the inflows and falls by the outflows
+	scale := newChartScale([]time.Time{months[0].Month, months[1].Month}, []float64{1000, 1350, 1250, 1250, 1250, 850})
+	width := (chartWidth - 2*chartMargin) / 2 / 2
+	want := []string{
+		fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.2f\" height=\"%.1f\" fill=\"#2ca02c\"/>", scale.x(jan), scale.y(1350), width, scale.y(1000)-scale.y(1350)),
+		fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.2f\" height=\"%.1f\" fill=\"#d62728\"/>", scale.x(jan)+width, scale.y(1350), width, scale.y(1250)-scale.y(1350)),
+		// A month without inflows still draws a sliver
+		fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.2f\" height=\"%.1f\" fill=\"#2ca02c\"/>", scale.x(months[1].Month), scale.y(1250), width, 0.5),
+		fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"%.2f\" height=\"%.1f\" fill=\"#d62728\"/>", scale.x(months[1].Month)+width, scale.y(1250), width, scale.y(850)-scale.y(1250)),
+	}
+	if got := strings.Count(chart, "<rect"); got != len(want) {
+		t.Errorf("%d bars, want %d", got, len(want))
+	}
+	for _, bar := range want {
+		if !strings.Contains(chart, bar) {
+			t.Errorf("missing bar %s", bar)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	for p, want := range map[float64]float64{0: 1, 10: 2, 25: 4, 50: 6, 75: 9, 90: 10, 100: 11} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile %.0f = %.0f, want %.0f", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of no values = %f", got)
+	}
+}
+
+func TestFanChart(t *testing.T) {
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates := []time.Time{jan, jan.AddDate(0, 1, 0)}
+
+	// Eleven runs of 100 to 1,100 the first month and twice that the second month, in no particular order
+	var runs [][]SeriesPoint
+	for _, i := range []int{7, 3, 11, 1, 9, 5, 2, 10, 4, 8, 6} {
+		runs = append(runs, []SeriesPoint{{dates[0], Dollars(USD(100 * i))}, {dates[1], Dollars(USD(200 * i))}})
+	}
+	chart := fanChart(runs)
+
+	scale := newChartScale(dates, []float64{200, 400}, []float64{1000, 2000})
+	median := fmt.Sprintf("points=\"%.1f,%.1f %.1f,%.1f\"", scale.x(dates[0]), scale.y(600), scale.x(dates[1]), scale.y(1200))
+	outer := fmt.Sprintf("points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f\"",
+		scale.x(dates[0]), scale.y(1000), scale.x(dates[1]), scale.y(2000), scale.x(dates[1]), scale.y(400), scale.x(dates[0]), scale.y(200))
+	inner := fmt.Sprintf("points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f\"",
+		scale.x(dates[0]), scale.y(900), scale.x(dates[1]), scale.y(1800), scale.x(dates[1]), scale.y(800), scale.x(dates[0]), scale.y(400))
+	for name, want := range map[string]string{"median": median, "10-90 band": outer, "25-75 band": inner, "run count": "of 11 runs"} {
+		if !strings.Contains(chart, want) {
+			t.Errorf("%s: missing %s", name, want)
+		}
+	}
+}
+
+func TestLoanChart(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewHTMLReport(&buf, "Report", "Checking")
+	r.Account(AccountDescription{Name: "Checking", Kind: "BankAccount"})
+	r.Account(AccountDescription{Name: "Mortgage", Kind: "LoanAccount"})
+
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		date := jan.AddDate(0, i, 0)
+		r.Snapshot(AccountInfo{Date: date, Account: "Checking", Period: PeriodBalance, AccountValue: Dollars(1000)})
+		r.Snapshot(AccountInfo{Date: date, Account: "Mortgage", Period: PeriodBalance, AccountValue: -Dollars(USD(1000 - 100*i)), CashFlow: Dollars(USD(100 * i)), Interest: Dollars(USD(5 * i))})
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Loans are drawn as amortization charts rather than with the account balances
+	out := buf.String()
+	balances := out[strings.Index(out, "<h2>Account Balances</h2>"):strings.Index(out, "<h2>Loan Amortization: Mortgage</h2>")]
+	if strings.Contains(balances, "Mortgage") || !strings.Contains(balances, "<h3>Checking</h3>") {
+		t.Errorf("account balances drawn for %s", balances)
+	}
+	loan := out[strings.Index(out, "<h2>Loan Amortization: Mortgage</h2>"):]
+	for _, series := range []string{"Principal Outstanding", "Principal Paid", "Interest Paid"} {
+		if !strings.Contains(loan, ">"+series+"</text>") {
+			t.Errorf("loan chart is missing %s", series)
+		}
+	}
+
+	// Principal outstanding is drawn positive, falling from $1,000 to $800
+	scale := newChartScale([]time.Time{jan, jan.AddDate(0, 2, 0)}, []float64{1000, 900, 800}, []float64{0, 100, 200}, []float64{0, 5, 10})
+	outstanding := fmt.Sprintf("points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f\"", scale.x(jan), scale.y(1000), scale.x(jan.AddDate(0, 1, 0)), scale.y(900), scale.x(jan.AddDate(0, 2, 0)), scale.y(800))
+	if !strings.Contains(loan, outstanding) {
+		t.Errorf("principal outstanding is not %s", outstanding)
+	}
+}
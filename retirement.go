@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// TaxTreatment is the tax treatment of a retirement account
+type TaxTreatment string
+
+// Retirement account tax treatments
+const (
+	PreTax = TaxTreatment("PRE-TAX")
+	Roth   = TaxTreatment("ROTH")
+)
+
+// CategoryEmployerMatch is the category of employer matching contributions, which do not count toward the
+// annual contribution limit.
+const CategoryEmployerMatch = "Employer Match"
+
+// uniformLifetime is the IRS Uniform Lifetime Table distribution period by age used for required minimum
+// distributions. Older ages use the last period.
+var uniformLifetime = map[int]float64{
+	72: 27.4, 73: 26.5, 74: 25.5, 75: 24.6, 76: 23.7, 77: 22.9, 78: 22.0, 79: 21.1,
+	80: 20.2, 81: 19.4, 82: 18.5, 83: 17.7, 84: 16.8, 85: 16.0, 86: 15.2, 87: 14.4, 88: 13.7, 89: 12.9,
+	90: 12.2, 91: 11.5, 92: 10.8, 93: 10.1, 94: 9.5, 95: 8.9, 96: 8.4, 97: 7.8, 98: 7.3, 99: 6.8,
+	100: 6.4,
+}
+
+// NewRetirementAccount creates a new 401(k) style retirement account for an owner born on the given date.
+// The limits, penalties and distribution age default to those of a 401(k) and can be changed on the account.
+func NewRetirementAccount(name string, date time.Time, init USD, treatment TaxTreatment, birthDate time.Time) *RetirementAccount {
+	return &RetirementAccount{
+		Name:         name,
+		Treatment:    treatment,
+		Balance:      init,
+		BirthDate:    birthDate,
+		AnnualLimit:  Dollars(23000),
+		CatchUpLimit: Dollars(7500),
+		CatchUpAge:   50,
+		PenaltyAge:   59.5,
+		PenaltyRate:  10,
+		RMDAge:       73,
+		Ledger: []Transaction{
+			Transaction{Date: date, Description: "Initial deposit", Type: Deposit, Amount: init, Balance: init},
+		},
+	}
+}
+
+// RetirementAccount is a tax advantaged retirement account such as a 401(k) or IRA. Employee contributions
+// are limited each calendar year, withdrawals before the penalty age are charged an early withdrawal
+// penalty, and pre-tax accounts must distribute a minimum amount each year to the distribution account
+// once the owner reaches the distribution age.
+//
+// Taxes withheld from pre-tax withdrawals and penalties are charged to the account in addition to the
+// amount withdrawn.
+type RetirementAccount struct {
+	Name            string
+	Treatment       TaxTreatment
+	Balance         USD
+	BirthDate       time.Time
+	ReturnRate      float64
+	AnnualLimit     USD
+	CatchUpLimit    USD
+	CatchUpAge      int
+	PenaltyAge      float64
+	PenaltyRate     float64
+	WithholdingRate float64
+	RMDAge          int
+	RMDTo           string
+	Contributions   USD
+	Distributions   USD
+	Penalties       USD
+	Withholding     USD
+	Ledger          []Transaction
+
+	year      int
+	yearStart USD
+}
+
+// Age returns the age of the owner on the date in years.
+func (a *RetirementAccount) Age(date time.Time) float64 {
+	return date.Sub(a.BirthDate).Hours() / 24 / 365.25
+}
+
+// Limit returns the employee contribution limit for the year of the date.
+func (a *RetirementAccount) Limit(date time.Time) USD {
+	if a.Age(time.Date(date.Year(), time.December, 31, 0, 0, 0, 0, date.Location())) >= float64(a.CatchUpAge) {
+		return a.AnnualLimit + a.CatchUpLimit
+	}
+	return a.AnnualLimit
+}
+
+// ContributionRoom returns the amount which can still be contributed during the year of the date.
+func (a *RetirementAccount) ContributionRoom(date time.Time) USD {
+	a.startYear(date)
+	if room := a.Limit(date) - a.Contributions; room > 0 {
+		return room
+	}
+	return 0
+}
+
+// startYear resets the yearly totals at the start of a new year.
+func (a *RetirementAccount) startYear(date time.Time) {
+	if date.Year() != a.year {
+		a.year = date.Year()
+		a.yearStart = a.Balance
+		a.Contributions = 0
+		a.Distributions = 0
+	}
+}
+
+// charges returns the early withdrawal penalty and tax withholding for a withdrawal.
+func (a *RetirementAccount) charges(tx Transaction) (penalty, withholding USD) {
+	if a.Age(tx.Date) < a.PenaltyAge {
+		penalty = tx.Amount.Percent(a.PenaltyRate, RoundHalfEven)
+	}
+	if a.Treatment == PreTax {
+		withholding = tx.Amount.Percent(a.WithholdingRate, RoundHalfEven)
+	}
+	return penalty, withholding
+}
+
+// CurrentBalance returns the current balance of the account
+func (a *RetirementAccount) CurrentBalance() USD {
+	return a.Balance
+}
+
+// MarketValue returns the current balance of the account
+func (a *RetirementAccount) MarketValue() USD {
+	return a.Balance
+}
+
+// Transactions returns the account ledger
+func (a *RetirementAccount) Transactions() []Transaction {
+	return a.Ledger
+}
+
+// Update grows the balance on the first of each month and makes the required minimum distribution on
+// the first of December.
+func (a *RetirementAccount) Update(ctx context.Context, proc Process, bank *Bank, date time.Time) {
+	a.startYear(date)
+	if date.Day() != 1 {
+		return
+	}
+	if a.ReturnRate != 0 {
+		a.Balance = a.Balance.Mul(math.Pow(1+a.ReturnRate/100., 1./12.), RoundHalfEven)
+	}
+
+	if date.Month() != time.December || a.Treatment != PreTax || a.RMDTo == "" {
+		return
+	}
+	age := int(a.Age(date))
+	if age < a.RMDAge {
+		return
+	}
+
+	period, ok := uniformLifetime[age]
+	if !ok {
+		period = uniformLifetime[100]
+	}
+	required := a.yearStart.Div(period, RoundHalfEven) - a.Distributions
+	_, withholding := a.charges(Transaction{Date: date, Amount: required})
+	if required+withholding > a.Balance {
+		required = a.Balance.Div(1+a.WithholdingRate/100., RoundTruncate)
+	}
+	if required <= 0 {
+		return
+	}
+
+	if err := bank.Transfer(date, a.Name, a.RMDTo, required); err != nil {
+		log.Println("ERR: ", err)
+		return
+	}
+	bank.Emit(date, a.Name, "RMD", fmt.Sprintf("Required minimum distribution of %s", required))
+}
+
+// Append appends a transaction to the account. Early withdrawal penalties and tax withholding are
+// appended as separate withdrawals in the Taxes category.
+func (a *RetirementAccount) Append(tx Transaction) error {
+	log.Println(a.Name, tx)
+	a.startYear(tx.Date)
+
+	if tx.Type == Deposit {
+		if tx.Category != CategoryEmployerMatch {
+			a.Contributions += tx.Amount
+		}
+		a.Balance += tx.Amount
+		tx.Balance = a.Balance
+		a.Ledger = append(a.Ledger, tx)
+		return nil
+	} else if tx.Type != Withdrawal {
+		return ErrUnknownTransactionType
+	}
+
+	penalty, withholding := a.charges(tx)
+	if tx.Amount+penalty+withholding > a.Balance {
+		return ErrInsufficientFunds
+	}
+
+	a.Balance -= tx.Amount
+	a.Distributions += tx.Amount
+	tx.Balance = a.Balance
+	a.Ledger = append(a.Ledger, tx)
+
+	charges := []struct {
+		desc   string
+		amount USD
+	}{
+		{"Early withdrawal penalty", penalty},
+		{"Tax withholding", withholding},
+	}
+	for _, c := range charges {
+		if c.amount == 0 {
+			continue
+		}
+		a.Balance -= c.amount
+		a.Ledger = append(a.Ledger, Transaction{Date: tx.Date, Type: Withdrawal, Description: c.desc, Category: CategoryTaxes, Amount: c.amount, Balance: a.Balance})
+	}
+	a.Penalties += penalty
+	a.Withholding += withholding
+	return nil
+}
+
+// Validate validates a transaction. Employee contributions may not exceed the annual limit and
+// withdrawals must cover any penalty and withholding.
+func (a *RetirementAccount) Validate(tx Transaction) bool {
+	if tx.Type == Deposit {
+		return tx.Category == CategoryEmployerMatch || tx.Amount <= a.ContributionRoom(tx.Date)
+	} else if tx.Type == Withdrawal {
+		penalty, withholding := a.charges(tx)
+		return tx.Amount+penalty+withholding <= a.Balance
+	}
+	return false
+}
+
+// String returns the string representation of the account
+func (a *RetirementAccount) String() string {
+	return fmt.Sprintf("%s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n\t- %s\t%s\n",
+		a.Name, a.Balance,
+		"Treatment:\t", a.Treatment,
+		"Contributions:\t", a.Contributions,
+		"Penalties:\t", a.Penalties,
+		"Withholding:\t", a.Withholding,
+	)
+}
+
+// RetirementContribution contributes a percentage of each salary payment to a retirement account. The
+// employer matches a percentage of the contribution up to a percentage of the salary. Contributions stop
+// at the annual limit and are not made when the salary is not paid.
+type RetirementContribution struct {
+	From         string
+	To           string
+	Salary       string
+	Percent      float64
+	MatchPercent float64
+	MatchLimit   float64
+}
+
+func (r *RetirementContribution) Description() string {
+	return fmt.Sprintf("RETIREMENT %s to %s\t%.1f%% of %s", r.From, r.To, r.Percent, r.Salary)
+}
+
+// Priority returns a priority just after income since contributions are deducted from each paycheck.
+func (r *RetirementContribution) Priority() int {
+	return PriorityIncome + 1
+}
+
+func (r *RetirementContribution) Process(date time.Time, bank *Bank) error {
+	from, ok := bank.Accounts[r.From]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+	acct, ok := bank.Accounts[r.To]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+	retirement, ok := acct.(*RetirementAccount)
+	if !ok {
+		return ErrInvalidTransfer
+	}
+
+	// Salary paid today
+	var salary USD
+	ledger := from.Transactions()
+	for i := len(ledger) - 1; i >= 0 && equalDates(ledger[i].Date, date); i-- {
+		if ledger[i].Type == Deposit && ledger[i].Description == r.Salary {
+			salary += ledger[i].Amount
+		}
+	}
+	if salary == 0 {
+		return nil
+	}
+
+	amount := salary.Percent(r.Percent, RoundHalfEven)
+	if room := retirement.ContributionRoom(date); amount > room {
+		amount = room
+	}
+	if amount <= 0 {
+		return nil
+	}
+	if err := bank.Transfer(date, r.From, r.To, amount); err != nil {
+		return err
+	}
+
+	// Only the contribution up to the match limit is matched
+	matched := amount
+	if limit := salary.Percent(r.MatchLimit, RoundHalfEven); matched > limit {
+		matched = limit
+	}
+	match := matched.Percent(r.MatchPercent, RoundHalfEven)
+	if match <= 0 {
+		return nil
+	}
+	desc := fmt.Sprintf("Employer match for '%s'", r.Salary)
+	return retirement.Append(Transaction{Date: date, Type: Deposit, Description: desc, Category: CategoryEmployerMatch, Amount: match})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetirementLimits(t *testing.T) {
+	born := time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC)
+	acct := NewRetirementAccount("401k", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 0, PreTax, born)
+
+	// The catch-up limit applies from the year the owner turns 50
+	tests := []struct {
+		year int
+		want USD
+	}{
+		{2018, Dollars(23000)},
+		{2034, Dollars(23000)},
+		{2035, Dollars(30500)},
+	}
+	for _, tt := range tests {
+		if got := acct.Limit(time.Date(tt.year, 1, 1, 0, 0, 0, 0, time.UTC)); got != tt.want {
+			t.Errorf("%d limit = %s, want %s", tt.year, got, tt.want)
+		}
+	}
+
+	date := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := acct.Append(Transaction{Date: date, Type: Deposit, Amount: Dollars(22000)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := acct.ContributionRoom(date); got != Dollars(1000) {
+		t.Errorf("room = %s", got)
+	}
+	if acct.Validate(Transaction{Date: date, Type: Deposit, Amount: Dollars(1001)}) {
+		t.Error("contribution over the limit")
+	}
+	if !acct.Validate(Transaction{Date: date, Type: Deposit, Category: CategoryEmployerMatch, Amount: Dollars(5000)}) {
+		t.Error("employer match limited")
+	}
+
+	// The limit resets each year
+	if got := acct.ContributionRoom(date.AddDate(1, 0, 0)); got != Dollars(23000) {
+		t.Errorf("next year room = %s", got)
+	}
+}
+
+func TestRetirementEarlyWithdrawal(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	acct := NewRetirementAccount("401k", date, Dollars(10000), PreTax, time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC))
+	acct.WithholdingRate = 20
+
+	// The 10% penalty and 20% withholding are charged in addition to the withdrawal
+	if acct.Validate(Transaction{Date: date, Type: Withdrawal, Amount: Dollars(8000)}) {
+		t.Error("withdrawal does not cover the charges")
+	}
+	if err := acct.Append(Transaction{Date: date, Type: Withdrawal, Amount: Dollars(1000)}); err != nil {
+		t.Fatal(err)
+	}
+	if acct.Balance != Dollars(8700) || acct.Penalties != Dollars(100) || acct.Withholding != Dollars(200) {
+		t.Errorf("balance = %s, penalties = %s, withholding = %s", acct.Balance, acct.Penalties, acct.Withholding)
+	}
+
+	var taxes USD
+	for _, tx := range acct.Ledger {
+		if tx.Category == CategoryTaxes {
+			taxes += tx.Amount
+		}
+	}
+	if taxes != Dollars(300) {
+		t.Errorf("taxes = %s", taxes)
+	}
+}
+
+func TestRetirementContribution(t *testing.T) {
+	date := time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC)
+	retirement := NewRetirementAccount("401k", date, 0, PreTax, time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC))
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", date.AddDate(0, 0, -1), Dollars(100)),
+		"401k":     retirement,
+	}}
+	contribution := &RetirementContribution{From: "Checking", To: "401k", Salary: "Salary", Percent: 10, MatchPercent: 50, MatchLimit: 6}
+
+	// Nothing is contributed without a salary
+	if err := contribution.Process(date, bank); err != nil || retirement.Balance != 0 {
+		t.Fatalf("contributed without a salary: %v %s", err, retirement.Balance)
+	}
+
+	// 10% of the salary is contributed and half of the first 6% is matched
+	bank.Append("Checking", Transaction{Date: date, Type: Deposit, Description: "Salary", Amount: Dollars(7000)})
+	if err := contribution.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if retirement.Contributions != Dollars(700) || retirement.Balance != Dollars(910) {
+		t.Errorf("contributions = %s, balance = %s", retirement.Contributions, retirement.Balance)
+	}
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(6400) {
+		t.Errorf("checking = %s", got)
+	}
+}
+
+func TestRequiredMinimumDistribution(t *testing.T) {
+	start := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	retirement := NewRetirementAccount("IRA", start, Dollars(265000), PreTax, time.Date(1945, 1, 1, 0, 0, 0, 0, time.UTC))
+	retirement.WithholdingRate = 20
+	retirement.RMDTo = "Checking"
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", start, 0),
+		"IRA":      retirement,
+	}}
+
+	// The owner is 73 so the distribution period is 26.5 years
+	retirement.Update(context.Background(), nil, bank, start)
+	retirement.Update(context.Background(), nil, bank, time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC))
+	if got := bank.Accounts["Checking"].CurrentBalance(); got != Dollars(10000) {
+		t.Errorf("distribution = %s", got)
+	}
+	if retirement.Balance != Dollars(253000) {
+		t.Errorf("balance = %s", retirement.Balance)
+	}
+	if len(bank.events) != 1 || bank.events[0].Type != "RMD" {
+		t.Errorf("events = %v", bank.events)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// InvestmentAccount is implemented by accounts holding investments which are valued separately from their cash.
+type InvestmentAccount interface {
+	MarketValue() USD
+	Transactions() []Transaction
+}
+
+// valuation is the value of an account at the end of a day and the external cash flow into it during the day.
+type valuation struct {
+	Date  time.Time
+	Value USD
+	Flow  USD
+}
+
+// NewReturnTracker creates a new return tracker.
+func NewReturnTracker() *ReturnTracker {
+	return &ReturnTracker{
+		history: map[string][]valuation{},
+		seen:    map[string]int{},
+	}
+}
+
+// ReturnTracker records the daily value of investment accounts along with the deposits and withdrawals
+// from their ledgers to compute money-weighted (XIRR) and time-weighted returns.
+type ReturnTracker struct {
+	history map[string][]valuation
+	seen    map[string]int
+}
+
+// Record records the value and external cash flows of each investment account for the day.
+func (r *ReturnTracker) Record(date time.Time, bank *Bank) {
+	for name, acct := range bank.Accounts {
+		investment, ok := acct.(InvestmentAccount)
+		if !ok {
+			continue
+		}
+
+		// Deposits and withdrawals are external cash flows. Loan payments and note trades are not.
+		var flow USD
+		ledger := investment.Transactions()
+		for _, tx := range ledger[r.seen[name]:] {
+			if tx.Type == Deposit {
+				flow += tx.Amount
+			} else if tx.Type == Withdrawal {
+				flow -= tx.Amount
+			}
+		}
+		r.seen[name] = len(ledger)
+		r.history[name] = append(r.history[name], valuation{date, investment.MarketValue(), flow})
+	}
+}
+
+// PeriodReturn is the return of an account over a period. Returns are percentages, annualized for periods
+// of a year or more.
+type PeriodReturn struct {
+	Start      time.Time
+	End        time.Time
+	XIRR       float64
+	TWR        float64
+	Annualized bool
+}
+
+// Annual returns the returns of an account for each calendar year.
+func (r *ReturnTracker) Annual(name string) []PeriodReturn {
+	history := r.history[name]
+	var returns []PeriodReturn
+
+	start := 0
+	for i := 1; i < len(history); i++ {
+		if i == len(history)-1 || history[i+1].Date.Year() != history[i].Date.Year() {
+			returns = append(returns, periodReturn(history[start:i+1]))
+			start = i
+		}
+	}
+	return returns
+}
+
+// Cumulative returns the return of an account over the whole simulation.
+func (r *ReturnTracker) Cumulative(name string) PeriodReturn {
+	return periodReturn(r.history[name])
+}
+
+// periodReturn computes the returns from the end of the first day to the end of the last day.
+func periodReturn(history []valuation) PeriodReturn {
+	if len(history) < 2 || !history[len(history)-1].Date.After(history[0].Date) {
+		return PeriodReturn{XIRR: math.NaN(), TWR: math.NaN()}
+	}
+	first, last := history[0], history[len(history)-1]
+	years := last.Date.Sub(first.Date).Hours() / 24 / 365
+
+	// Time-weighted return chains the daily returns excluding the external flows
+	growth := 1.
+	for i := 1; i < len(history); i++ {
+		if prev := history[i-1].Value; prev > 0 {
+			growth *= float64(history[i].Value-history[i].Flow) / float64(prev)
+		}
+	}
+
+	// Money-weighted return treats the starting value as the initial investment
+	flows := []valuation{{first.Date, -first.Value, 0}}
+	for _, v := range history[1:] {
+		if v.Flow != 0 {
+			flows = append(flows, valuation{v.Date, -v.Flow, 0})
+		}
+	}
+	flows = append(flows, valuation{last.Date, last.Value, 0})
+
+	// Returns for part of a year are not annualized, since a few good days would compound into
+	// an absurd annual rate
+	if years < 1 {
+		return PeriodReturn{
+			Start: first.Date,
+			End:   last.Date,
+			XIRR:  xirr(flows, years) * 100,
+			TWR:   (growth - 1) * 100,
+		}
+	}
+	return PeriodReturn{
+		Start:      first.Date,
+		End:        last.Date,
+		XIRR:       xirr(flows, 1) * 100,
+		TWR:        (math.Pow(growth, 1/years) - 1) * 100,
+		Annualized: true,
+	}
+}
+
+// xirr solves for the rate per period, in years, at which the net present value of the cash flows is zero.
+// Flows are stored in the Value field of each valuation.
+func xirr(flows []valuation, period float64) float64 {
+	npv := func(rate float64) float64 {
+		var total float64
+		for _, f := range flows {
+			years := f.Date.Sub(flows[0].Date).Hours() / 24 / 365
+			total += float64(f.Value) / math.Pow(1+rate, years/period)
+		}
+		return total
+	}
+
+	lo, hi := -0.99, 10.
+	if npv(lo)*npv(hi) > 0 {
+		return math.NaN()
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// String returns the annual and cumulative returns of every tracked account.
+func (r *ReturnTracker) String() string {
+	var names []string
+	for name := range r.history {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s Returns\n\t%s\t%s\t%s\n", name, "Year", "XIRR", "TWR")
+		for _, ret := range r.Annual(name) {
+			fmt.Fprintf(&buf, "\t%d\t%.2f%%\t%.2f%%\n", ret.End.Year(), ret.XIRR, ret.TWR)
+		}
+		ret := r.Cumulative(name)
+		fmt.Fprintf(&buf, "\t%s\t%.2f%%\t%.2f%%\n", "Total", ret.XIRR, ret.TWR)
+	}
+	return buf.String()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestXIRR(t *testing.T) {
+	d := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return d.AddDate(0, 0, n) }
+
+	tests := []struct {
+		name   string
+		flows  []valuation
+		period float64
+		want   float64
+	}{
+		{"one year", []valuation{{d, -1000, 0}, {days(365), 1100, 0}}, 1, 0.10},
+		{"two years", []valuation{{d, -1000, 0}, {days(730), 1210, 0}}, 1, 0.10},
+		{"two deposits", []valuation{{d, -1000, 0}, {days(365), -1000, 0}, {days(730), 2310, 0}}, 1, 0.10},
+		{"loss", []valuation{{d, -1000, 0}, {days(365), 800, 0}}, 1, -0.20},
+		{"partial period", []valuation{{d, -1000, 0}, {days(73), 1050, 0}}, 73. / 365, 0.05},
+		{"no sign change", []valuation{{d, 1000, 0}, {days(365), 1100, 0}}, 1, math.NaN()},
+		{"no return", []valuation{{d, -1000, 0}, {days(365), 0, 0}}, 1, math.NaN()},
+	}
+	for _, tt := range tests {
+		got := xirr(tt.flows, tt.period)
+		if math.IsNaN(tt.want) {
+			if !math.IsNaN(got) {
+				t.Errorf("%s: xirr = %f, want NaN", tt.name, got)
+			}
+		} else if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: xirr = %f, want %f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodReturn(t *testing.T) {
+	d := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return d.AddDate(0, 0, n) }
+
+	tests := []struct {
+		name       string
+		history    []valuation
+		xirr, twr  float64
+		annualized bool
+	}{
+		{"doubled over two years", []valuation{{d, 1000, 0}, {days(365), 1500, 0}, {days(730), 2000, 0}}, 41.421356, 41.421356, true},
+		{"half year", []valuation{{d, 1000, 0}, {days(91), 1020, 0}, {days(182), 1050, 0}}, 5, 5, false},
+		{"single day", []valuation{{d, 1000, 0}, {days(1), 1010, 0}}, 1, 1, false},
+
+		// The deposit is excluded from the time-weighted return but weighted by the money-weighted return
+		{"deposit", []valuation{{d, 1000, 0}, {days(100), 2100, 1000}, {days(200), 2310, 0}}, 21, 21, false},
+	}
+	for _, tt := range tests {
+		got := periodReturn(tt.history)
+		if !got.Start.Equal(tt.history[0].Date) || !got.End.Equal(tt.history[len(tt.history)-1].Date) {
+			t.Errorf("%s: period %s to %s", tt.name, got.Start, got.End)
+		}
+		if got.Annualized != tt.annualized {
+			t.Errorf("%s: annualized = %v, want %v", tt.name, got.Annualized, tt.annualized)
+		}
+		if math.Abs(got.TWR-tt.twr) > 1e-4 {
+			t.Errorf("%s: TWR = %f, want %f", tt.name, got.TWR, tt.twr)
+		}
+		if math.Abs(got.XIRR-tt.xirr) > 1e-4 {
+			t.Errorf("%s: XIRR = %f, want %f", tt.name, got.XIRR, tt.xirr)
+		}
+	}
+
+	if got := periodReturn([]valuation{{d, 1000, 0}}); !math.IsNaN(got.XIRR) || !math.IsNaN(got.TWR) {
+		t.Errorf("single valuation returned %f XIRR and %f TWR, want NaN", got.XIRR, got.TWR)
+	}
+}
+
+func TestAnnualReturns(t *testing.T) {
+	start := time.Date(2018, 12, 30, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The value is flat in 2018, grows 10% evenly through 2019 and 0.1% on the first day of 2020
+	r := NewReturnTracker()
+	value := 1000.
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		if date.Year() == 2019 {
+			value *= math.Pow(1.1, 1./365)
+		} else if date.Year() == 2020 {
+			value *= 1.001
+		}
+		r.history["Investment"] = append(r.history["Investment"], valuation{date, USD(math.Round(value * 100)), 0})
+	}
+
+	annual := r.Annual("Investment")
+	if len(annual) != 3 {
+		t.Fatalf("%d annual returns, want 3", len(annual))
+	}
+	want := []struct {
+		year       int
+		twr        float64
+		annualized bool
+	}{
+		{2018, 0, false},
+		{2019, 10, true},
+		{2020, 0.1, false},
+	}
+	for i, w := range want {
+		got := annual[i]
+		if got.End.Year() != w.year || got.Annualized != w.annualized {
+			t.Errorf("%d: year %d annualized %v, want %d %v", i, got.End.Year(), got.Annualized, w.year, w.annualized)
+		}
+		if math.Abs(got.TWR-w.twr) > 0.01 || math.Abs(got.XIRR-w.twr) > 0.01 {
+			t.Errorf("%d: TWR %.4f%% XIRR %.4f%%, want %.4f%%", w.year, got.TWR, got.XIRR, w.twr)
+		}
+	}
+
+	// Consecutive years share their boundary day so no day's return is lost
+	if !annual[0].End.Equal(annual[1].Start) || !annual[1].End.Equal(annual[2].Start) {
+		t.Errorf("annual periods do not chain: %v", annual)
+	}
+
+	cumulative := r.Cumulative("Investment")
+	years := end.Sub(start).Hours() / 24 / 365
+	if twr := (math.Pow(1.1*1.001, 1/years) - 1) * 100; math.Abs(cumulative.TWR-twr) > 0.01 || !cumulative.Annualized {
+		t.Errorf("cumulative TWR = %.4f%%, want annualized %.4f%%", cumulative.TWR, twr)
+	}
+	if !cumulative.Start.Equal(start) || !cumulative.End.Equal(end) {
+		t.Errorf("cumulative period %s to %s", cumulative.Start, cumulative.End)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import "math"
+
+// RoundingMode is how fractions of a cent are rounded to a whole cent.
+type RoundingMode int
+
+// Rounding modes
+const (
+	// RoundHalfEven rounds to the nearest cent and ties to the even cent, as banks do for interest.
+	RoundHalfEven RoundingMode = iota
+
+	// RoundHalfUp rounds to the nearest cent and ties away from zero.
+	RoundHalfUp
+
+	// RoundTruncate drops the fraction of a cent, so the result never exceeds the exact amount.
+	RoundTruncate
+)
+
+// maxSnap is the largest number of cents which can be snapped to a millionth of a cent without losing precision.
+const maxSnap = 1e9
+
+// Round rounds a fractional number of cents to a whole cent. Amounts are first snapped to a millionth of a
+// cent so that float64 representation error, such as 2.4999999999999996, does not move a tie.
+func (m RoundingMode) Round(cents float64) USD {
+	if math.Abs(cents) < maxSnap {
+		cents = math.Round(cents*1e6) / 1e6
+	}
+
+	switch m {
+	case RoundHalfUp:
+		cents = math.Round(cents)
+	case RoundTruncate:
+		cents = math.Trunc(cents)
+	default:
+		cents = math.RoundToEven(cents)
+	}
+	return USD(cents)
+}
+
+// String returns the name of the rounding mode.
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfUp:
+		return "half-up"
+	case RoundTruncate:
+		return "truncate"
+	}
+	return "half-even"
+}
+
+// Mul multiplies the amount by a factor such as a rate or growth factor.
+func (u USD) Mul(factor float64, mode RoundingMode) USD {
+	return mode.Round(float64(u) * factor)
+}
+
+// Div divides the amount by a divisor such as a distribution period.
+func (u USD) Div(divisor float64, mode RoundingMode) USD {
+	return mode.Round(float64(u) / divisor)
+}
+
+// Percent returns the percentage of the amount.
+func (u USD) Percent(percent float64, mode RoundingMode) USD {
+	return mode.Round(float64(u) * percent / 100.)
+}
+
+// Split divides the amount into n parts which add up to the amount. The remainder is distributed a cent at a
+// time to the first parts.
+func (u USD) Split(n int) []USD {
+	if n <= 0 {
+		return nil
+	}
+
+	parts := make([]USD, n)
+	part, remainder := u/USD(n), u%USD(n)
+	for i := range parts {
+		parts[i] = part
+		if remainder > 0 {
+			parts[i]++
+			remainder--
+		} else if remainder < 0 {
+			parts[i]--
+			remainder++
+		}
+	}
+	return parts
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundingModes(t *testing.T) {
+	dime := 0.1
+	tests := []struct {
+		cents                  float64
+		halfEven, halfUp, trnc USD
+	}{
+		// Ties
+		{2.5, 2, 3, 2},
+		{3.5, 4, 4, 3},
+		{-2.5, -2, -3, -2},
+		{-3.5, -4, -4, -3},
+		{0.5, 0, 1, 0},
+		{-0.5, 0, -1, 0},
+
+		// Representation error does not move a tie
+		{2.4999999999999996, 2, 3, 2},
+		{dime*3*100 - 29.5, 0, 1, 0},
+
+		// Not ties
+		{2.4, 2, 2, 2},
+		{2.6, 3, 3, 2},
+		{-2.6, -3, -3, -2},
+
+		// Large amounts are not snapped
+		{1e9 + 0.5, 1e9, 1e9 + 1, 1e9},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			mode RoundingMode
+			want USD
+		}{
+			{RoundHalfEven, tt.halfEven},
+			{RoundHalfUp, tt.halfUp},
+			{RoundTruncate, tt.trnc},
+		} {
+			if got := c.mode.Round(tt.cents); got != c.want {
+				t.Errorf("%s.Round(%v) = %d, want %d", c.mode, tt.cents, got, c.want)
+			}
+		}
+	}
+}
+
+func TestUSDArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  USD
+		want USD
+	}{
+		{"Mul tie half-even", USD(-5).Mul(0.5, RoundHalfEven), -2},
+		{"Mul tie half-up", USD(-5).Mul(0.5, RoundHalfUp), -3},
+		{"Mul truncate", USD(-5).Mul(0.5, RoundTruncate), -2},
+		{"Div tie half-even", USD(-1001).Div(2, RoundHalfEven), -500},
+		{"Div tie half-up", USD(-1001).Div(2, RoundHalfUp), -501},
+		{"Div truncate", USD(1001).Div(3, RoundTruncate), 333},
+		{"Percent tie half-even", USD(250).Percent(1, RoundHalfEven), 2},
+		{"Percent tie half-up", USD(250).Percent(1, RoundHalfUp), 3},
+		{"Percent negative", Dollars(-100).Percent(7.5, RoundHalfEven), -750},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		amount USD
+		n      int
+		want   []USD
+	}{
+		{100, 3, []USD{34, 33, 33}},
+		{-100, 3, []USD{-34, -33, -33}},
+		{1, 4, []USD{1, 0, 0, 0}},
+		{-2, 4, []USD{-1, -1, 0, 0}},
+		{0, 2, []USD{0, 0}},
+		{100, 0, nil},
+	}
+	for _, tt := range tests {
+		got := tt.amount.Split(tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("%d.Split(%d) = %v, want %v", tt.amount, tt.n, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%d.Split(%d) = %v, want %v", tt.amount, tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// The parts always add up to the amount and differ by at most a cent
+	for _, amount := range []USD{Dollars(40000), 4200001, -4200001, 11, -11} {
+		for n := 1; n <= 13; n++ {
+			var sum USD
+			parts := amount.Split(n)
+			for _, part := range parts {
+				sum += part
+			}
+			if sum != amount || parts[0]-parts[n-1] > 1 || parts[n-1]-parts[0] > 1 {
+				t.Errorf("%d.Split(%d) = %v", amount, n, parts)
+			}
+		}
+	}
+}
+
+func TestLoanAmortization(t *testing.T) {
+	tests := []struct {
+		amount USD
+		apr    float64
+		years  int
+	}{
+		{Dollars(100000), 4, 30},
+		{Dollars(173600), 4.875, 30},
+		{Dollars(20000), 2, 5},
+		{1234567, 7.25, 15},
+	}
+	for _, tt := range tests {
+		start := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+		loan := NewLoan("Loan", tt.amount, tt.apr, tt.years, 0)
+		checking := NewBankAccount("Checking", start, Dollars(10000000))
+		bank := &Bank{Accounts: map[string]Account{"Checking": checking, "Loan": loan}}
+		payment := &LoanPayment{From: "Checking", To: "Loan", DayOfMonth: 2}
+
+		for month := 0; month < tt.years*12+2; month++ {
+			if err := payment.Process(start.AddDate(0, month, 0), bank); err != nil {
+				t.Fatalf("%s at %.3f%%: %v", tt.amount, tt.apr, err)
+			}
+		}
+
+		// Principal and interest add up to every cent paid, and the principal to the loan amount
+		paid := Dollars(10000000) - checking.Balance
+		if loan.PrincipalPaid+loan.InterestPaid != paid {
+			t.Errorf("%s at %.3f%%: principal %s + interest %s != paid %s", tt.amount, tt.apr, loan.PrincipalPaid, loan.InterestPaid, paid)
+		}
+		if loan.PrincipalPaid != tt.amount || loan.PayoffAmount() != 0 {
+			t.Errorf("%s at %.3f%%: principal paid %s, payoff %s", tt.amount, tt.apr, loan.PrincipalPaid, loan.PayoffAmount())
+		}
+		var payments int
+		for _, tx := range loan.Ledger {
+			if tx.Type == Deposit {
+				payments++
+			}
+		}
+		if payments != tt.years*12 {
+			t.Errorf("%s at %.3f%%: %d payments", tt.amount, tt.apr, payments)
+		}
+	}
+}
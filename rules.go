@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Condition is evaluated against the state of the bank on a date. Goals are conditions and can be used
+// in rules.
+type Condition interface {
+	Name() string
+	Met(date time.Time, bank *Bank) bool
+}
+
+// accountBalance returns the balance of an account. The balance of a loan is the principal remaining.
+func accountBalance(bank *Bank, name string) (USD, bool) {
+	acct, ok := bank.Accounts[name]
+	if !ok {
+		return 0, false
+	}
+	if loan, ok := acct.(*LoanAccount); ok {
+		return loan.PayoffAmount(), true
+	}
+	return acct.CurrentBalance(), true
+}
+
+// BalanceAbove is met when the balance of an account is greater than the amount.
+type BalanceAbove struct {
+	Account string
+	Amount  USD
+}
+
+func (c *BalanceAbove) Name() string {
+	return fmt.Sprintf("%s > %s", c.Account, c.Amount)
+}
+
+func (c *BalanceAbove) Met(date time.Time, bank *Bank) bool {
+	balance, ok := accountBalance(bank, c.Account)
+	return ok && balance > c.Amount
+}
+
+// BalanceBelow is met when the balance of an account is less than the amount.
+type BalanceBelow struct {
+	Account string
+	Amount  USD
+}
+
+func (c *BalanceBelow) Name() string {
+	return fmt.Sprintf("%s < %s", c.Account, c.Amount)
+}
+
+func (c *BalanceBelow) Met(date time.Time, bank *Bank) bool {
+	balance, ok := accountBalance(bank, c.Account)
+	return ok && balance < c.Amount
+}
+
+// OnDayOfMonth is met on the given day of each month.
+type OnDayOfMonth struct {
+	Day int
+}
+
+func (c *OnDayOfMonth) Name() string {
+	return fmt.Sprintf("day %d", c.Day)
+}
+
+func (c *OnDayOfMonth) Met(date time.Time, bank *Bank) bool {
+	return date.Day() == c.Day
+}
+
+// Between is met from the start date until the end date. A zero date is unbounded.
+type Between struct {
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+func (c *Between) Name() string {
+	return fmt.Sprintf("between %s and %s", c.StartDate.Format("2006-01-02"), c.EndDate.Format("2006-01-02"))
+}
+
+func (c *Between) Met(date time.Time, bank *Bank) bool {
+	if !c.StartDate.IsZero() && date.Before(c.StartDate) {
+		return false
+	}
+	return c.EndDate.IsZero() || !date.After(c.EndDate)
+}
+
+// All is met when every condition is met.
+type All []Condition
+
+func (c All) Name() string {
+	return joinConditions(c, " and ")
+}
+
+func (c All) Met(date time.Time, bank *Bank) bool {
+	for _, cond := range c {
+		if !cond.Met(date, bank) {
+			return false
+		}
+	}
+	return true
+}
+
+// Any is met when at least one condition is met.
+type Any []Condition
+
+func (c Any) Name() string {
+	return joinConditions(c, " or ")
+}
+
+func (c Any) Met(date time.Time, bank *Bank) bool {
+	for _, cond := range c {
+		if cond.Met(date, bank) {
+			return true
+		}
+	}
+	return false
+}
+
+// Not is met when the condition is not met.
+type Not struct {
+	Condition Condition
+}
+
+func (c *Not) Name() string {
+	return fmt.Sprintf("not (%s)", c.Condition.Name())
+}
+
+func (c *Not) Met(date time.Time, bank *Bank) bool {
+	return !c.Condition.Met(date, bank)
+}
+
+func joinConditions(conds []Condition, sep string) string {
+	var names []string
+	for _, cond := range conds {
+		names = append(names, cond.Name())
+	}
+	return "(" + strings.Join(names, sep) + ")"
+}
+
+// ConditionalItem processes a line item only while the condition is met, for example pausing
+// investment transfers while the emergency fund is low.
+type ConditionalItem struct {
+	When Condition
+	Item LineItem
+}
+
+func (c *ConditionalItem) Description() string {
+	return fmt.Sprintf("IF %s THEN %s", c.When.Name(), c.Item.Description())
+}
+
+func (c *ConditionalItem) Process(date time.Time, bank *Bank) error {
+	if !c.When.Met(date, bank) {
+		return nil
+	}
+	return c.Item.Process(date, bank)
+}
+
+// Sweep transfers the balance above the threshold from one account to another whenever the condition is met.
+type Sweep struct {
+	From      string
+	To        string
+	Threshold USD
+	When      Condition
+}
+
+func (s *Sweep) Description() string {
+	return fmt.Sprintf("SWEEP %s above %s to %s", s.From, s.Threshold, s.To)
+}
+
+func (s *Sweep) Process(date time.Time, bank *Bank) error {
+	if s.When != nil && !s.When.Met(date, bank) {
+		return nil
+	}
+
+	balance, ok := accountBalance(bank, s.From)
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+	if balance <= s.Threshold {
+		return nil
+	}
+	return bank.Transfer(date, s.From, s.To, balance-s.Threshold)
+}
+
+// EarlyPayoff pays off the remaining principal of a loan from an account when the condition is met and the
+// account can afford it.
+type EarlyPayoff struct {
+	From string
+	Loan string
+	When Condition
+}
+
+func (p *EarlyPayoff) Description() string {
+	return fmt.Sprintf("PAYOFF %s from %s", p.Loan, p.From)
+}
+
+func (p *EarlyPayoff) Process(date time.Time, bank *Bank) error {
+	if p.When != nil && !p.When.Met(date, bank) {
+		return nil
+	}
+
+	acct, ok := bank.Accounts[p.Loan]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+	loan, ok := acct.(*LoanAccount)
+	if !ok {
+		return ErrInvalidTransfer
+	}
+	from, ok := bank.Accounts[p.From]
+	if !ok {
+		return ErrAccountDoesNotExist
+	}
+
+	amount := loan.PayoffAmount()
+	if amount == 0 {
+		return nil
+	}
+
+	// Accounts in other currencies pay the principal at the exchange rate
+	withdrawn, err := bank.Convert(date, amount, accountCurrency(loan), accountCurrency(from))
+	if err != nil {
+		return err
+	}
+
+	desc := fmt.Sprintf("Payoff of '%s' from '%s'", p.Loan, p.From)
+	withdrawal := Transaction{Date: date, Type: Withdrawal, Description: desc, Category: CategoryLoanPayment, Amount: withdrawn}
+	payoff := Transaction{Date: date, Type: Payoff, Description: desc, Category: CategoryLoanPayment, Amount: amount}
+	if !loan.Validate(payoff) {
+		return ErrInvalidTransfer
+	}
+
+	// The loan is paid off once the account can afford it
+	if !from.Validate(withdrawal) {
+		return nil
+	}
+	if err := from.Append(withdrawal); err != nil {
+		return err
+	}
+	bank.Emit(date, p.Loan, "PAYOFF", desc)
+	return loan.Append(payoff)
+}
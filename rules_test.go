@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEarlyPayoff(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := NewLoan("Mortgage", Dollars(20000), 4, 5, 0)
+	checking := NewBankAccount("Checking", date, Dollars(1000))
+	bank := &Bank{Accounts: map[string]Account{"Checking": checking, "Mortgage": loan}}
+	payoff := &EarlyPayoff{From: "Checking", Loan: "Mortgage"}
+
+	// The payoff waits until checking can afford it
+	if err := payoff.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if loan.PayoffAmount() == 0 || checking.Balance != Dollars(1000) {
+		t.Fatalf("paid off without funds: %s %s", loan.PayoffAmount(), checking.Balance)
+	}
+
+	amount := loan.PayoffAmount()
+	checking.Append(Transaction{Date: date, Type: Deposit, Amount: Dollars(20000)})
+	if err := payoff.Process(date.AddDate(0, 0, 1), bank); err != nil {
+		t.Fatal(err)
+	}
+	if loan.PayoffAmount() != 0 || checking.Balance != Dollars(21000)-amount {
+		t.Errorf("payoff = %s, checking = %s", loan.PayoffAmount(), checking.Balance)
+	}
+	if len(bank.events) != 1 || bank.events[0].Type != "PAYOFF" {
+		t.Errorf("events = %v", bank.events)
+	}
+
+	// Nothing is left to pay
+	if err := payoff.Process(date.AddDate(0, 0, 2), bank); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEarlyPayoffInCurrency(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	loan := NewLoan("Mortgage", Dollars(20000), 4, 5, 0)
+	savings := NewCurrencyAccount("Euro Savings", date, Dollars(30000), CurrencyEUR)
+	bank := &Bank{Accounts: map[string]Account{"Euro Savings": savings, "Mortgage": loan}}
+	payoff := &EarlyPayoff{From: "Euro Savings", Loan: "Mortgage"}
+
+	// Without an exchange rate the loan is not paid
+	if err := payoff.Process(date, bank); err != ErrNoExchangeRate {
+		t.Fatalf("err = %v, want %v", err, ErrNoExchangeRate)
+	}
+
+	// The $20,000 principal costs €16,000
+	bank.FX = FixedRates{CurrencyEUR: 0.8}
+	if err := payoff.Process(date, bank); err != nil {
+		t.Fatal(err)
+	}
+	if loan.PayoffAmount() != 0 || savings.Balance != Dollars(14000) {
+		t.Errorf("payoff = %s, savings = %s", loan.PayoffAmount(), savings.Balance)
+	}
+	if last := loan.Ledger[len(loan.Ledger)-1]; last.Type != Payoff || last.Amount != Dollars(20000) {
+		t.Errorf("loan recorded %s %s", last.Type, last.Amount)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	checking := NewBankAccount("Checking", date, Dollars(5000))
+	savings := NewBankAccount("Savings", date, 0)
+	bank := &Bank{Accounts: map[string]Account{"Checking": checking, "Savings": savings}}
+	sweep := &Sweep{From: "Checking", To: "Savings", Threshold: Dollars(1000), When: &OnDayOfMonth{Day: 1}}
+
+	// Nothing is swept when the condition is not met
+	if err := sweep.Process(date.AddDate(0, 0, 1), bank); err != nil || savings.Balance != 0 {
+		t.Fatalf("swept %s off the day, err %v", savings.Balance, err)
+	}
+
+	// The balance above the threshold is swept once
+	for i := 0; i < 2; i++ {
+		if err := sweep.Process(date, bank); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checking.Balance != Dollars(1000) || savings.Balance != Dollars(4000) {
+		t.Errorf("checking = %s, savings = %s", checking.Balance, savings.Balance)
+	}
+
+	if err := (&Sweep{From: "Brokerage", To: "Savings"}).Process(date, bank); err != ErrAccountDoesNotExist {
+		t.Errorf("err = %v, want %v", err, ErrAccountDoesNotExist)
+	}
+}
+
+func TestConditionalItem(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	checking := NewBankAccount("Checking", date, Dollars(1500))
+	brokerage := NewBankAccount("Brokerage", date, 0)
+	bank := &Bank{Accounts: map[string]Account{"Checking": checking, "Brokerage": brokerage}}
+
+	// Investing pauses while the emergency fund is low
+	item := &ConditionalItem{
+		When: &BalanceAbove{Account: "Checking", Amount: Dollars(1000)},
+		Item: &MonthlyTransfer{From: "Checking", To: "Brokerage", Amount: Dollars(400), DayOfMonth: 1, StartDate: date, EndDate: date.AddDate(1, 0, 0)},
+	}
+	for month := 0; month < 3; month++ {
+		if err := item.Process(date.AddDate(0, month, 0), bank); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checking.Balance != Dollars(700) || brokerage.Balance != Dollars(800) {
+		t.Errorf("checking = %s, brokerage = %s", checking.Balance, brokerage.Balance)
+	}
+	if got := ItemPriority(item); got != PrioritySavings {
+		t.Errorf("priority = %d, want the transfer's %d", got, PrioritySavings)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	date := time.Date(2018, 1, 15, 0, 0, 0, 0, time.UTC)
+	loan := NewLoan("Mortgage", Dollars(20000), 4, 5, 0)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", date, Dollars(500)),
+		"Mortgage": loan,
+	}}
+	yes, no := &OnDayOfMonth{Day: 15}, &OnDayOfMonth{Day: 1}
+
+	tests := []struct {
+		cond Condition
+		name string
+		want bool
+	}{
+		{All{yes, yes}, "(day 15 and day 15)", true},
+		{All{yes, no}, "(day 15 and day 1)", false},
+		{All{}, "()", true},
+		{Any{no, yes}, "(day 1 or day 15)", true},
+		{Any{no, no}, "(day 1 or day 1)", false},
+		{Any{}, "()", false},
+		{&Not{no}, "not (day 1)", true},
+		{&Not{All{yes, &Not{no}}}, "not ((day 15 and not (day 1)))", false},
+		{&Between{StartDate: date, EndDate: date}, "between 2018-01-15 and 2018-01-15", true},
+		{&Between{StartDate: date.AddDate(0, 0, 1)}, "between 2018-01-16 and 0001-01-01", false},
+
+		// The balance of a loan is the principal owed rather than the payments left
+		{&BalanceBelow{Account: "Mortgage", Amount: Dollars(20001)}, "Mortgage < " + Dollars(20001).String(), true},
+		{&BalanceBelow{Account: "Mortgage", Amount: Dollars(20000)}, "Mortgage < " + Dollars(20000).String(), false},
+		{&BalanceAbove{Account: "Mortgage", Amount: Dollars(19999)}, "Mortgage > " + Dollars(19999).String(), true},
+		{&BalanceBelow{Account: "Savings", Amount: Dollars(1000)}, "Savings < " + Dollars(1000).String(), false},
+		{&BalanceAbove{Account: "Checking", Amount: Dollars(500)}, "Checking > " + Dollars(500).String(), false},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.Met(date, bank); got != tt.want {
+			t.Errorf("%s: met = %v, want %v", tt.cond.Name(), got, tt.want)
+		}
+		if tt.cond.Name() != tt.name {
+			t.Errorf("name = %q, want %q", tt.cond.Name(), tt.name)
+		}
+	}
+
+	// Once the loan is paid off its balance is below any threshold
+	loan.Append(Transaction{Date: date, Type: Payoff, Amount: loan.PayoffAmount()})
+	if !(&BalanceBelow{Account: "Mortgage", Amount: 1}).Met(date, bank) {
+		t.Error("paid off loan is not below $0.01")
+	}
+}
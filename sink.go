@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DefaultColumns are the snapshot columns written by the CSV sink by default.
+var DefaultColumns = []string{"date", "available", "value", "cashflow", "interest"}
+
+// snapshotColumns formats each of the supported snapshot columns.
+var snapshotColumns = map[string]func(AccountInfo) string{
+	"date":      func(info AccountInfo) string { return info.Date.Format("2006-01-02") },
+	"account":   func(info AccountInfo) string { return info.Account },
+	"period":    func(info AccountInfo) string { return info.Period },
+	"available": func(info AccountInfo) string { return formatDollars(info.AvailableCash) },
+	"value":     func(info AccountInfo) string { return formatDollars(info.AccountValue) },
+	"cashflow":  func(info AccountInfo) string { return formatDollars(info.CashFlow) },
+	"interest":  func(info AccountInfo) string { return formatDollars(info.Interest) },
+}
+
+func formatDollars(u USD) string {
+	return strconv.FormatFloat(u.Float64(), 'f', 2, 64)
+}
+
+// NewCSVSink creates a CSV sink writing the snapshots of a period with the given columns. Transactions
+// and events are written to their own writers, and are skipped if the writer is nil.
+func NewCSVSink(snapshots, transactions, events io.Writer, period string, columns ...string) (*CSVSink, error) {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	for _, col := range columns {
+		if _, ok := snapshotColumns[col]; !ok {
+			return nil, fmt.Errorf("Unknown column '%s'", col)
+		}
+	}
+
+	sink := &CSVSink{Period: period, Columns: columns}
+	if snapshots != nil {
+		sink.snapshots = csv.NewWriter(snapshots)
+		sink.snapshots.Write(columns)
+	}
+	if transactions != nil {
+		sink.transactions = csv.NewWriter(transactions)
+		sink.transactions.Write([]string{"date", "account", "type", "description", "amount"})
+	}
+	if events != nil {
+		sink.events = csv.NewWriter(events)
+		sink.events.Write([]string{"date", "account", "type", "description"})
+	}
+	return sink, nil
+}
+
+// CSVSink writes the simulation output as CSV.
+type CSVSink struct {
+	Period  string
+	Columns []string
+
+	snapshots    *csv.Writer
+	transactions *csv.Writer
+	events       *csv.Writer
+}
+
+// Account is a no-op for CSV output.
+func (c *CSVSink) Account(acct AccountDescription) error {
+	return nil
+}
+
+// Snapshot writes a row with the configured columns if the snapshot is for the sink period.
+func (c *CSVSink) Snapshot(info AccountInfo) error {
+	if c.snapshots == nil || (c.Period != "" && info.Period != c.Period) {
+		return nil
+	}
+
+	row := make([]string, len(c.Columns))
+	for i, col := range c.Columns {
+		row[i] = snapshotColumns[col](info)
+	}
+	return c.snapshots.Write(row)
+}
+
+// Transaction writes a transaction row.
+func (c *CSVSink) Transaction(tx AccountTransaction) error {
+	if c.transactions == nil {
+		return nil
+	}
+	return c.transactions.Write([]string{
+		tx.Date.Format("2006-01-02"),
+		tx.Account,
+		string(tx.Type),
+		tx.Description,
+		formatDollars(tx.Amount),
+	})
+}
+
+// Event writes an event row.
+func (c *CSVSink) Event(e Event) error {
+	if c.events == nil {
+		return nil
+	}
+	return c.events.Write([]string{e.Date.Format("2006-01-02"), e.Account, e.Type, e.Description})
+}
+
+// Close flushes the buffered rows.
+func (c *CSVSink) Close() error {
+	for _, w := range []*csv.Writer{c.snapshots, c.transactions, c.events} {
+		if w == nil {
+			continue
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewJSONLinesSink creates a sink which writes each record as a JSON object on its own line.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{json.NewEncoder(w)}
+}
+
+// JSONLinesSink writes the simulation output as JSON Lines. Every record has a "kind" field
+// of "account", "snapshot", "transaction" or "event".
+type JSONLinesSink struct {
+	enc *json.Encoder
+}
+
+type jsonAccount struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	AccountType string `json:"type"`
+}
+
+type jsonSnapshot struct {
+	Kind          string  `json:"kind"`
+	Date          string  `json:"date"`
+	Account       string  `json:"account"`
+	Period        string  `json:"period"`
+	AvailableCash float64 `json:"available"`
+	AccountValue  float64 `json:"value"`
+	CashFlow      float64 `json:"cashflow"`
+	Interest      float64 `json:"interest"`
+}
+
+type jsonTransaction struct {
+	Kind        string  `json:"kind"`
+	Date        string  `json:"date"`
+	Account     string  `json:"account"`
+	Type        string  `json:"type"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+type jsonEvent struct {
+	Kind        string `json:"kind"`
+	Date        string `json:"date"`
+	Account     string `json:"account"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Account writes an account record.
+func (j *JSONLinesSink) Account(acct AccountDescription) error {
+	return j.enc.Encode(jsonAccount{"account", acct.Name, acct.Kind})
+}
+
+// Snapshot writes a snapshot record.
+func (j *JSONLinesSink) Snapshot(info AccountInfo) error {
+	return j.enc.Encode(jsonSnapshot{
+		Kind:          "snapshot",
+		Date:          info.Date.Format("2006-01-02"),
+		Account:       info.Account,
+		Period:        info.Period,
+		AvailableCash: info.AvailableCash.Float64(),
+		AccountValue:  info.AccountValue.Float64(),
+		CashFlow:      info.CashFlow.Float64(),
+		Interest:      info.Interest.Float64(),
+	})
+}
+
+// Transaction writes a transaction record.
+func (j *JSONLinesSink) Transaction(tx AccountTransaction) error {
+	return j.enc.Encode(jsonTransaction{
+		Kind:        "transaction",
+		Date:        tx.Date.Format("2006-01-02"),
+		Account:     tx.Account,
+		Type:        string(tx.Type),
+		Description: tx.Description,
+		Amount:      tx.Amount.Float64(),
+	})
+}
+
+// Event writes an event record.
+func (j *JSONLinesSink) Event(e Event) error {
+	return j.enc.Encode(jsonEvent{"event", e.Date.Format("2006-01-02"), e.Account, e.Type, e.Description})
+}
+
+// Close is a no-op. The underlying writer is owned by the caller.
+func (j *JSONLinesSink) Close() error {
+	return nil
+}
+
+// NewPropertySink creates a sink which writes the daily value, loan balance and equity of each property
+// account as CSV.
+func NewPropertySink(w io.Writer) *PropertySink {
+	sink := &PropertySink{w: csv.NewWriter(w), loans: map[string]string{}, values: map[string]USD{}}
+	sink.w.Write([]string{"date", "account", "value", "loan", "equity"})
+	return sink
+}
+
+// PropertySink writes property valuations from the end of day balances. The loan balance is the
+// principal outstanding on the loan account financing the property.
+type PropertySink struct {
+	w          *csv.Writer
+	properties []string
+	loans      map[string]string
+	date       time.Time
+	values     map[string]USD
+}
+
+// Account records the property accounts and the loans financing them.
+func (p *PropertySink) Account(acct AccountDescription) error {
+	if acct.Kind == "PropertyAccount" {
+		p.properties = append(p.properties, acct.Name)
+		p.loans[acct.Name] = acct.Loan
+	}
+	return nil
+}
+
+// Snapshot records the end of day balances. The rows of a day are written once the balances of the
+// next day arrive.
+func (p *PropertySink) Snapshot(info AccountInfo) error {
+	if info.Period != PeriodBalance {
+		return nil
+	}
+	if !info.Date.Equal(p.date) {
+		p.flush()
+		p.date = info.Date
+	}
+	p.values[info.Account] = info.AccountValue
+	return nil
+}
+
+// flush writes the property rows of the current day. Sold properties are skipped.
+func (p *PropertySink) flush() {
+	if p.date.IsZero() {
+		return
+	}
+	for _, name := range p.properties {
+		value := p.values[name]
+		if value == 0 {
+			continue
+		}
+
+		// Loan account values are the negative principal outstanding
+		var loan USD
+		if p.loans[name] != "" {
+			loan = -p.values[p.loans[name]]
+		}
+		p.w.Write([]string{p.date.Format("2006-01-02"), name, formatDollars(value), formatDollars(loan), formatDollars(value - loan)})
+	}
+	p.values = map[string]USD{}
+}
+
+// Transaction is a no-op for property output.
+func (p *PropertySink) Transaction(tx AccountTransaction) error {
+	return nil
+}
+
+// Event is a no-op for property output.
+func (p *PropertySink) Event(e Event) error {
+	return nil
+}
+
+// Close writes the last day and flushes the buffered rows.
+func (p *PropertySink) Close() error {
+	p.flush()
+	p.w.Flush()
+	return p.w.Error()
+}
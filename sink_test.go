@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSinkRecords writes an account, two snapshots, a transaction and an event to a sink and closes it.
+func writeSinkRecords(t *testing.T, sink Sink) {
+	date := time.Date(2018, 1, 31, 0, 0, 0, 0, time.UTC)
+	records := []error{
+		sink.Account(AccountDescription{Name: "Checking", Kind: "BankAccount"}),
+		sink.Snapshot(AccountInfo{Date: date, Account: "Checking", Period: PeriodBalance, AvailableCash: 123456, AccountValue: 123456}),
+		sink.Snapshot(AccountInfo{Date: date, Account: "Checking", Period: PeriodMonthly, AvailableCash: 123456, AccountValue: 123456, CashFlow: -5001, Interest: 7}),
+		sink.Transaction(AccountTransaction{Account: "Checking", Transaction: Transaction{Date: date, Type: Withdrawal, Description: `Dinner at "Joe's", downtown`, Amount: 5001}}),
+		sink.Event(Event{Date: date, Account: "Checking", Type: "MILESTONE", Description: "Saved $1,000"}),
+		sink.Close(),
+	}
+	for _, err := range records {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	var snapshots, transactions, events bytes.Buffer
+	sink, err := NewCSVSink(&snapshots, &transactions, &events, PeriodMonthly, "account", "date", "cashflow", "interest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSinkRecords(t, sink)
+
+	// Only the monthly snapshot is written, with the columns in the given order
+	tests := []struct {
+		name string
+		buf  *bytes.Buffer
+		want [][]string
+	}{
+		{"snapshots", &snapshots, [][]string{
+			{"account", "date", "cashflow", "interest"},
+			{"Checking", "2018-01-31", "-50.01", "0.07"},
+		}},
+		{"transactions", &transactions, [][]string{
+			{"date", "account", "type", "description", "amount"},
+			{"2018-01-31", "Checking", string(Withdrawal), `Dinner at "Joe's", downtown`, "50.01"},
+		}},
+		{"events", &events, [][]string{
+			{"date", "account", "type", "description"},
+			{"2018-01-31", "Checking", "MILESTONE", "Saved $1,000"},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := csv.NewReader(tt.buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Writers which are not given are skipped
+	sink, err = NewCSVSink(&snapshots, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSinkRecords(t, sink)
+
+	if _, err := NewCSVSink(&snapshots, nil, nil, "", "date", "balance"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	var buf bytes.Buffer
+	writeSinkRecords(t, NewJSONLinesSink(&buf))
+
+	want := []map[string]interface{}{
+		{"kind": "account", "name": "Checking", "type": "BankAccount"},
+		{"kind": "snapshot", "date": "2018-01-31", "account": "Checking", "period": PeriodBalance, "available": 1234.56, "value": 1234.56, "cashflow": 0., "interest": 0.},
+		{"kind": "snapshot", "date": "2018-01-31", "account": "Checking", "period": PeriodMonthly, "available": 1234.56, "value": 1234.56, "cashflow": -50.01, "interest": 0.07},
+		{"kind": "transaction", "date": "2018-01-31", "account": "Checking", "type": string(Withdrawal), "description": `Dinner at "Joe's", downtown`, "amount": 50.01},
+		{"kind": "event", "date": "2018-01-31", "account": "Checking", "type": "MILESTONE", "description": "Saved $1,000"},
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("%d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("line %d: got %v, want %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestSQLiteSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "simulation.db")
+
+	// A second run replaces the tables of the first
+	for i := 0; i < 2; i++ {
+		sink, err := NewSQLiteSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeSinkRecords(t, sink)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		query string
+		want  []interface{}
+	}{
+		{`SELECT name, type FROM accounts`, []interface{}{"Checking", "BankAccount"}},
+		{`SELECT date, account, period, available, value, cashflow, interest FROM snapshots WHERE period = 'monthly'`, []interface{}{"2018-01-31", "Checking", PeriodMonthly, int64(123456), int64(123456), int64(-5001), int64(7)}},
+		{`SELECT date, account, type, description, amount FROM transactions`, []interface{}{"2018-01-31", "Checking", string(Withdrawal), `Dinner at "Joe's", downtown`, int64(5001)}},
+		{`SELECT date, account, type, description FROM events`, []interface{}{"2018-01-31", "Checking", "MILESTONE", "Saved $1,000"}},
+		{`SELECT COUNT(*) FROM snapshots`, []interface{}{int64(2)}},
+	}
+	for _, tt := range tests {
+		got := make([]interface{}, len(tt.want))
+		dest := make([]interface{}, len(tt.want))
+		for i := range got {
+			dest[i] = &got[i]
+		}
+		if err := db.QueryRow(tt.query).Scan(dest...); err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		for i := range got {
+			if b, ok := got[i].([]byte); ok {
+				got[i] = string(b)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPropertySink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewPropertySink(&buf)
+	sink.Account(AccountDescription{Name: "Home", Kind: "PropertyAccount", Loan: "Mortgage"})
+	sink.Account(AccountDescription{Name: "Mortgage", Kind: "LoanAccount"})
+
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []USD{Dollars(300000), Dollars(301000), 0} {
+		date := day.AddDate(0, 0, i)
+		sink.Snapshot(AccountInfo{Date: date, Account: "Home", Period: PeriodBalance, AccountValue: value})
+		sink.Snapshot(AccountInfo{Date: date, Account: "Mortgage", Period: PeriodBalance, AccountValue: -Dollars(200000)})
+		sink.Snapshot(AccountInfo{Date: date, Account: "Home", Period: PeriodMonthly, AccountValue: Dollars(1)})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sold property is left out on the last day
+	want := "date,account,value,loan,equity\n" +
+		"2018-01-01,Home,300000.00,200000.00,100000.00\n" +
+		"2018-01-02,Home,301000.00,200000.00,101000.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema creates the tables of a simulation run. Money is stored in cents.
+var sqliteSchema = []string{
+	`DROP TABLE IF EXISTS accounts`,
+	`DROP TABLE IF EXISTS snapshots`,
+	`DROP TABLE IF EXISTS transactions`,
+	`DROP TABLE IF EXISTS events`,
+	`CREATE TABLE accounts (name TEXT PRIMARY KEY, type TEXT)`,
+	`CREATE TABLE snapshots (date TEXT, account TEXT, period TEXT, available INTEGER, value INTEGER, cashflow INTEGER, interest INTEGER)`,
+	`CREATE TABLE transactions (date TEXT, account TEXT, type TEXT, description TEXT, amount INTEGER)`,
+	`CREATE TABLE events (date TEXT, account TEXT, type TEXT, description TEXT)`,
+}
+
+// NewSQLiteSink creates a sink writing to an SQLite database at the given path. Existing
+// tables from a previous run are replaced.
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// Everything is written in a single transaction which is committed on close
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	sink := &SQLiteSink{db: db, tx: tx}
+	stmts := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&sink.accounts, `INSERT OR REPLACE INTO accounts VALUES (?, ?)`},
+		{&sink.snapshots, `INSERT INTO snapshots VALUES (?, ?, ?, ?, ?, ?, ?)`},
+		{&sink.transactions, `INSERT INTO transactions VALUES (?, ?, ?, ?, ?)`},
+		{&sink.events, `INSERT INTO events VALUES (?, ?, ?, ?)`},
+	}
+	for _, s := range stmts {
+		if *s.stmt, err = tx.Prepare(s.query); err != nil {
+			tx.Rollback()
+			db.Close()
+			return nil, err
+		}
+	}
+	return sink, nil
+}
+
+// SQLiteSink writes the simulation output to an embedded SQLite database with accounts,
+// snapshots, transactions and events tables.
+type SQLiteSink struct {
+	db           *sql.DB
+	tx           *sql.Tx
+	accounts     *sql.Stmt
+	snapshots    *sql.Stmt
+	transactions *sql.Stmt
+	events       *sql.Stmt
+}
+
+// Account inserts an account row.
+func (s *SQLiteSink) Account(acct AccountDescription) error {
+	_, err := s.accounts.Exec(acct.Name, acct.Kind)
+	return err
+}
+
+// Snapshot inserts a snapshot row.
+func (s *SQLiteSink) Snapshot(info AccountInfo) error {
+	_, err := s.snapshots.Exec(info.Date.Format("2006-01-02"), info.Account, info.Period,
+		int64(info.AvailableCash), int64(info.AccountValue), int64(info.CashFlow), int64(info.Interest))
+	return err
+}
+
+// Transaction inserts a transaction row.
+func (s *SQLiteSink) Transaction(tx AccountTransaction) error {
+	_, err := s.transactions.Exec(tx.Date.Format("2006-01-02"), tx.Account, string(tx.Type), tx.Description, int64(tx.Amount))
+	return err
+}
+
+// Event inserts an event row.
+func (s *SQLiteSink) Event(e Event) error {
+	_, err := s.events.Exec(e.Date.Format("2006-01-02"), e.Account, e.Type, e.Description)
+	return err
+}
+
+// Close commits the rows and closes the database.
+func (s *SQLiteSink) Close() error {
+	if err := s.tx.Commit(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
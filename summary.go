@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// SummaryYear holds the totals of a single year of the simulation.
+type SummaryYear struct {
+	Year           int
+	Income         USD
+	Expenses       USD
+	Transfers      USD
+	InterestEarned USD
+	InterestPaid   USD
+	Taxes          USD
+	Balances       map[string]USD
+	NetWorth       USD
+}
+
+// Milestone is a notable point in the simulation.
+type Milestone struct {
+	Date        time.Time
+	Description string
+}
+
+// NewSummaryReport creates a summary which is written to the writer when the sink is closed.
+func NewSummaryReport(w io.Writer) *SummaryReport {
+	return &SummaryReport{
+		w:        w,
+		kinds:    map[string]string{},
+		years:    map[int]*SummaryYear{},
+		last:     map[string]AccountInfo{},
+		negative: map[string]bool{},
+	}
+}
+
+// SummaryReport is a sink which totals the income, expenses, transfers, interest and taxes of each year along
+// with the year end balances, and records milestones such as loans being paid off and accounts going negative.
+//
+// Income and expenses are the deposits and withdrawals of bank accounts other than transfers. Loan payments are
+// expenses. Taxes are withdrawals in the Taxes category from any account.
+type SummaryReport struct {
+	Milestones []Milestone
+
+	w        io.Writer
+	names    []string
+	kinds    map[string]string
+	years    map[int]*SummaryYear
+	last     map[string]AccountInfo
+	negative map[string]bool
+
+	date          time.Time
+	netWorth      USD
+	negativeWorth bool
+	millions      int64
+	started       bool
+}
+
+// year returns the totals of the year, creating them if necessary.
+func (s *SummaryReport) year(date time.Time) *SummaryYear {
+	y, ok := s.years[date.Year()]
+	if !ok {
+		y = &SummaryYear{Year: date.Year(), Balances: map[string]USD{}}
+		s.years[date.Year()] = y
+	}
+	return y
+}
+
+// milestone records a milestone unless it has already been recorded for the date.
+func (s *SummaryReport) milestone(date time.Time, format string, args ...interface{}) {
+	m := Milestone{date, fmt.Sprintf(format, args...)}
+	for _, prev := range s.Milestones {
+		if prev == m {
+			return
+		}
+	}
+	s.Milestones = append(s.Milestones, m)
+}
+
+// Account records the account type.
+func (s *SummaryReport) Account(acct AccountDescription) error {
+	if _, ok := s.kinds[acct.Name]; !ok {
+		s.names = append(s.names, acct.Name)
+	}
+	s.kinds[acct.Name] = acct.Kind
+	return nil
+}
+
+// Snapshot adds the daily interest earned and records the end of day balances.
+func (s *SummaryReport) Snapshot(info AccountInfo) error {
+	switch info.Period {
+	case PeriodDaily:
+		s.year(info.Date).InterestEarned += info.Interest
+	case PeriodBalance:
+		if !info.Date.Equal(s.date) {
+			s.closeDay()
+			s.date = info.Date
+		}
+		s.netWorth += info.AccountValue
+
+		y := s.year(info.Date)
+		y.Balances[info.Account] = info.AccountValue
+
+		prev, seen := s.last[info.Account]
+		s.last[info.Account] = info
+		if s.kinds[info.Account] == "LoanAccount" {
+			// Loan interest is cumulative and includes payments made before the simulation
+			if seen {
+				y.InterestPaid += info.Interest - prev.Interest
+			}
+			if seen && prev.AccountValue < 0 && info.AccountValue == 0 {
+				s.milestone(info.Date, "%s paid off", info.Account)
+			}
+		} else if info.AccountValue < 0 && !s.negative[info.Account] {
+			s.negative[info.Account] = true
+			s.milestone(info.Date, "%s first negative", info.Account)
+		}
+	}
+	return nil
+}
+
+// closeDay records the net worth milestones of the previous day.
+func (s *SummaryReport) closeDay() {
+	if s.date.IsZero() {
+		return
+	}
+
+	y := s.year(s.date)
+	y.NetWorth = s.netWorth
+	if s.netWorth < 0 && !s.negativeWorth {
+		s.negativeWorth = true
+		s.milestone(s.date, "Net worth first negative")
+	}
+	// The net worth at the start of the simulation is not a milestone
+	if millions := int64(s.netWorth / Dollars(1000000)); millions > s.millions {
+		if s.started {
+			s.milestone(s.date, "Net worth reached %s", Dollars(USD(millions)*1000000))
+		}
+		s.millions = millions
+	}
+	s.started = true
+	s.netWorth = 0
+}
+
+// Transaction adds the deposits and withdrawals of bank accounts to the yearly totals.
+func (s *SummaryReport) Transaction(tx AccountTransaction) error {
+	y := s.year(tx.Date)
+	if tx.Category == CategoryTaxes && tx.Type == Withdrawal && s.kinds[tx.Account] != "BankAccount" {
+		y.Taxes += tx.Amount
+		return nil
+	} else if s.kinds[tx.Account] != "BankAccount" {
+		return nil
+	}
+
+	switch {
+	case tx.Category == CategoryTransfer && tx.Type == Withdrawal:
+		y.Transfers += tx.Amount
+	case tx.Category == CategoryTransfer:
+	case tx.Category == CategoryTaxes && tx.Type == Withdrawal:
+		y.Taxes += tx.Amount
+	case tx.Type == Deposit:
+		y.Income += tx.Amount
+	case tx.Type == Withdrawal:
+		y.Expenses += tx.Amount
+	}
+	return nil
+}
+
+// Event adds the event as a milestone.
+func (s *SummaryReport) Event(e Event) error {
+	if e.Account == "" {
+		s.milestone(e.Date, "%s", e.Description)
+		return nil
+	}
+	s.milestone(e.Date, "%s: %s", e.Account, e.Description)
+	return nil
+}
+
+// Years returns the yearly totals in order.
+func (s *SummaryReport) Years() []SummaryYear {
+	var years []SummaryYear
+	for _, y := range s.years {
+		years = append(years, *y)
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i].Year < years[j].Year })
+	return years
+}
+
+// String returns the yearly totals as a table followed by the milestones.
+func (s *SummaryReport) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "Year\tIncome\tExpenses\tTransfers\tInterest Earned\tInterest Paid\tTaxes\t")
+	for _, name := range s.names {
+		fmt.Fprintf(w, "%s\t", name)
+	}
+	fmt.Fprint(w, "Net Worth\t\n")
+
+	for _, y := range s.Years() {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t", y.Year, y.Income, y.Expenses, y.Transfers, y.InterestEarned, y.InterestPaid, y.Taxes)
+		for _, name := range s.names {
+			fmt.Fprintf(w, "%s\t", y.Balances[name])
+		}
+		fmt.Fprintf(w, "%s\t\n", y.NetWorth)
+	}
+	w.Flush()
+
+	if len(s.Milestones) > 0 {
+		milestones := append([]Milestone(nil), s.Milestones...)
+		sort.SliceStable(milestones, func(i, j int) bool { return milestones[i].Date.Before(milestones[j].Date) })
+
+		fmt.Fprintln(&buf, "\nMilestones")
+		for _, m := range milestones {
+			fmt.Fprintf(&buf, "\t%s %s\n", m.Description, m.Date.Format("2006-01-02"))
+		}
+	}
+	return buf.String()
+}
+
+// Close records the final day and writes the summary.
+func (s *SummaryReport) Close() error {
+	s.closeDay()
+	_, err := io.WriteString(s.w, s.String())
+	return err
+}
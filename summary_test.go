@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestSummaryLoanPayments(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	bank := &Bank{Accounts: map[string]Account{
+		"Checking": NewBankAccount("Checking", date, Dollars(10000)),
+		"Savings":  NewBankAccount("Savings", date, 0),
+		"Mortgage": NewLoan("Mortgage", Dollars(100000), 4, 30, 0),
+	}}
+	if err := bank.Transfer(date, "Checking", "Savings", Dollars(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bank.Transfer(date, "Checking", "Mortgage", Dollars(500)); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := NewSummaryReport(ioutil.Discard)
+	summary.Account(AccountDescription{Name: "Checking", Kind: "BankAccount"})
+	summary.Account(AccountDescription{Name: "Savings", Kind: "BankAccount"})
+	summary.Account(AccountDescription{Name: "Mortgage", Kind: "LoanAccount"})
+	for _, entry := range bank.Ledger("Checking", "Savings", "Mortgage") {
+		if entry.Description != "Initial deposit" {
+			summary.Transaction(entry)
+		}
+	}
+
+	// The mortgage payment is spent, the savings transfer is not
+	y := summary.year(date)
+	if y.Expenses != Dollars(500) || y.Transfers != Dollars(1000) || y.Income != 0 {
+		t.Errorf("expenses = %s, transfers = %s, income = %s", y.Expenses, y.Transfers, y.Income)
+	}
+}